@@ -24,6 +24,8 @@ import (
 	"github.com/knative/pkg/controller"
 	"github.com/knative/pkg/logging"
 	"github.com/knative/pkg/signals"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
 	"k8s.io/client-go/dynamic"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -32,6 +34,8 @@ import (
 
 	servingclientset "github.com/knative/serving/pkg/client/clientset/versioned"
 	servinginformers "github.com/knative/serving/pkg/client/informers/externalversions"
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+	"github.com/vaikas-google/csr/pkg/backend"
 	clientset "github.com/vaikas-google/csr/pkg/client/clientset/versioned"
 	informers "github.com/vaikas-google/csr/pkg/client/informers/externalversions"
 	"github.com/vaikas-google/csr/pkg/reconciler/cloudschedulersource"
@@ -42,10 +46,13 @@ const (
 )
 
 var (
-	masterURL  = flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
-	kubeconfig = flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	masterURL  = flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	kubeconfig = flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	// TODO(mattmoor): Move into a configmap and use the watcher.
-	raImage = flag.String("raimage", "", "The name of the Receive Adapter image, see //cmd/receivedapter")
+	raImage        = flag.String("raimage", "", "The name of the Receive Adapter image, see //cmd/receivedapter")
+	defaultBackend = flag.String("backend", string(v1alpha1.SchedulerBackendCloudScheduler), "The scheduler backend for sources that don't specify one, CloudScheduler, InCluster or CronJob.")
+	// Used for local development against //cmd/fakescheduler.
+	schedulerEndpoint = flag.String("schedulerendpoint", "", "Overrides the Cloud Scheduler API endpoint. The connection is made without TLS or authentication.")
 )
 
 func main() {
@@ -81,6 +88,37 @@ func main() {
 		logger.Fatalf("Error building serving clientset: %s", err.Error())
 	}
 
+	var schedulerOpts []option.ClientOption
+	if *schedulerEndpoint != "" {
+		schedulerOpts = append(schedulerOpts,
+			option.WithEndpoint(*schedulerEndpoint),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithInsecure()))
+	}
+	backendKind := v1alpha1.SchedulerBackend(*defaultBackend)
+	jobBackends := map[v1alpha1.SchedulerBackend]backend.JobBackend{
+		v1alpha1.SchedulerBackendInCluster: backend.NewInCluster(logger, nil),
+	}
+	cloudScheduler, err := backend.NewCloudScheduler(context.Background(), schedulerOpts...)
+	if err == nil {
+		jobBackends[v1alpha1.SchedulerBackendCloudScheduler] = cloudScheduler
+	} else if backendKind == v1alpha1.SchedulerBackendCloudScheduler {
+		logger.Fatalf("Error building cloud scheduler client: %s", err.Error())
+	} else {
+		// Clusters without Google Cloud credentials can still use the other backends.
+		logger.Warnf("Cloud Scheduler backend is not available: %s", err.Error())
+	}
+	// Only needed by the sources with a PubSubTarget.
+	pubSub, err := backend.NewPubSub(context.Background())
+	if err != nil {
+		logger.Warnf("Pub/Sub is not available: %s", err.Error())
+	}
+	// CronJobs are managed by the reconciler directly rather than through a JobBackend.
+	if _, ok := jobBackends[backendKind]; !ok && backendKind != v1alpha1.SchedulerBackendCronJob {
+		logger.Fatalf("Unknown scheduler backend: %q", *defaultBackend)
+	}
+	v1alpha1.DefaultBackend = backendKind
+
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, time.Second*30)
 	cloudSchedulerSourceInformerFactory := informers.NewSharedInformerFactory(cloudSchedulerSourceClient, time.Second*30)
 
@@ -90,6 +128,9 @@ func main() {
 	servingInformerFactory := servinginformers.NewSharedInformerFactory(servingClient, time.Second*30)
 	servingInformer := servingInformerFactory.Serving().V1alpha1().Services()
 
+	cronJobInformer := kubeInformerFactory.Batch().V1beta1().CronJobs()
+	deploymentInformer := kubeInformerFactory.Apps().V1().Deployments()
+
 	// Add new controllers here.
 	controllers := []*controller.Impl{
 		cloudschedulersource.NewController(
@@ -100,7 +141,13 @@ func main() {
 			cloudSchedulerSourceInformer,
 			servingClient,
 			servingInformer,
+			cronJobInformer,
+			deploymentInformer,
+			jobBackends,
+			backendKind,
+			pubSub,
 			*raImage,
+			stopCh,
 		),
 	}
 
@@ -113,6 +160,8 @@ func main() {
 	for i, synced := range []cache.InformerSynced{
 		cloudSchedulerSourceInformer.Informer().HasSynced,
 		servingInformer.Informer().HasSynced,
+		cronJobInformer.Informer().HasSynced,
+		deploymentInformer.Informer().HasSynced,
 	} {
 		if ok := cache.WaitForCacheSync(stopCh, synced); !ok {
 			logger.Fatalf("failed to wait for cache at index %v to sync", i)
@@ -39,7 +39,7 @@ func myFunc(ctx context.Context, e string) error {
 
 func main() {
 	m := cloudevents.NewMux()
-	err := m.Handle("GoogleCloudScheduler", myFunc)
+	err := m.Handle("com.google.cloud.scheduler.job.execute", myFunc)
 	if err != nil {
 		log.Fatalf("Failed to create handler %s", err)
 	}
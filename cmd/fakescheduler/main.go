@@ -0,0 +1,47 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vaikas-google/csr/pkg/testing/fakescheduler"
+)
+
+func main() {
+	port := flag.Int("port", 8090, "port to serve the fake Cloud Scheduler API on")
+
+	flag.Parse()
+
+	s := fakescheduler.New()
+	addr, stop, err := s.Start(fmt.Sprintf(":%d", *port))
+	if err != nil {
+		log.Fatalf("Failed to start fake Cloud Scheduler: %s", err)
+	}
+	defer stop()
+
+	log.Printf("Fake Cloud Scheduler listening on %q", addr)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+}
@@ -33,7 +33,7 @@ func myFunc(ctx context.Context, e string) (string, cloudevents.EventContext, er
 
 func main() {
 	m := cloudevents.NewMux()
-	err := m.Handle("GoogleCloudScheduler", myFunc)
+	err := m.Handle("com.google.cloud.scheduler.job.execute", myFunc)
 	if err != nil {
 		log.Fatalf("Failed to create handler %s", err)
 	}
@@ -17,10 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub/apiv1"
 
 	"github.com/vaikas-google/csr/pkg/receiveadapter"
 )
@@ -28,10 +34,34 @@ import (
 const (
 	// Environment variable containing the HTTP port
 	envPort = "PORT"
+
+	// Environment variable containing the ID of the event to send with --once
+	envEventID = "EVENT_ID"
 )
 
 func main() {
 	sink := flag.String("sink", "", "uri to send events to")
+	deadLetterSink := flag.String("dead-letter-sink", "", "uri to send the events the sink didn't accept to")
+	once := flag.Bool("once", false, "send a single event and exit instead of serving requests")
+	body := flag.String("body", "", "body of the event to send with --once")
+	subscription := flag.String("subscription", "", "Pub/Sub subscription to pull events from instead of serving requests")
+	jobName := flag.String("job-name", "", "ID of the job publishing to the --subscription, added to the events as the jobname extension")
+	forwardHeaders := flag.String("forward-headers", "", "comma separated names of the request headers to add to the events as extensions")
+	eventType := flag.String("event-type", receiveadapter.EventType, "type attribute of the events")
+	eventSource := flag.String("event-source", receiveadapter.EventSource, "source attribute of the events")
+	specVersion := flag.String("cloudevents-spec-version", receiveadapter.SpecVersion01, "version of the CloudEvents spec to send events with, 0.1, 0.2 or 1.0")
+	encoding := flag.String("cloudevents-encoding", "Binary", "how to encode events, Binary or Structured")
+	retry := receiveadapter.DefaultRetryPolicy
+	flag.IntVar(&retry.MaxAttempts, "retry-max-attempts", retry.MaxAttempts, "how many times an event is sent to the sink at most")
+	flag.DurationVar(&retry.MinBackoff, "retry-min-backoff", retry.MinBackoff, "wait before the first retry, doubling with every retry")
+	flag.DurationVar(&retry.MaxBackoff, "retry-max-backoff", retry.MaxBackoff, "longest wait between retries")
+	jitterPercent := flag.Int("retry-jitter-percent", int(retry.Jitter*100), "how much, in percent, the waits between retries are randomized")
+	retryCodes := flag.String("retry-status-codes", "", "comma separated statuses of the responses of the sink to retry, 429, 502, 503 and 504 if empty")
+	dedupCacheSize := flag.Int("dedup-cache-size", 0, "how many delivered events to remember to drop their repeated deliveries, none if 0")
+	dedupTTL := flag.Duration("dedup-ttl", time.Hour, "how long to remember delivered events for, until evicted if 0")
+	requestTimeout := flag.Duration("request-timeout", 2*time.Minute, "how long to try sending the event of a request for")
+	oidcEmail := flag.String("oidc-email", "", "email of the service account incoming requests must carry an OIDC token of")
+	oidcAudience := flag.String("oidc-audience", "", "audience of the OIDC tokens of incoming requests, the URL of the request if empty")
 
 	flag.Parse()
 
@@ -39,6 +69,77 @@ func main() {
 		log.Fatalf("No sink given")
 	}
 
+	retry.Jitter = float64(*jitterPercent) / 100
+	if *retryCodes != "" {
+		retry.RetryableStatusCodes = nil
+		for _, c := range strings.Split(*retryCodes, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(c))
+			if err != nil {
+				log.Fatalf("Invalid status code %q: %s", c, err)
+			}
+			retry.RetryableStatusCodes = append(retry.RetryableStatusCodes, code)
+		}
+	}
+
+	format := receiveadapter.Format{SpecVersion: *specVersion}
+	switch *encoding {
+	case "Binary":
+	case "Structured":
+		format.Structured = true
+	default:
+		log.Fatalf("Unsupported CloudEvents encoding %q", *encoding)
+	}
+	if err := format.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	ra := &receiveadapter.CloudSchedulerReceiveAdapter{
+		Sink:           *sink,
+		EventType:      *eventType,
+		EventSource:    *eventSource,
+		Format:         format,
+		Retry:          &retry,
+		DeadLetterSink: *deadLetterSink,
+		RequestTimeout: *requestTimeout,
+		JobName:        *jobName,
+	}
+	if *dedupCacheSize > 0 {
+		dedup, err := receiveadapter.NewDedupCache(*dedupCacheSize, *dedupTTL)
+		if err != nil {
+			log.Fatalf("Failed to create the deduplication cache: %s", err)
+		}
+		ra.Dedup = dedup
+	}
+	if *forwardHeaders != "" {
+		ra.ForwardHeaders = strings.Split(*forwardHeaders, ",")
+	}
+
+	if *once {
+		if err := ra.Send(*body, os.Getenv(envEventID)); err != nil {
+			log.Fatalf("Failed to send event: %s", err)
+		}
+		return
+	}
+
+	if *subscription != "" {
+		ctx := context.Background()
+		client, err := pubsub.NewSubscriberClient(ctx)
+		if err != nil {
+			log.Fatalf("Failed to create Pub/Sub client: %s", err)
+		}
+		log.Printf("Pulling from %q, sink is: %q", *subscription, *sink)
+		log.Fatal(ra.Pull(ctx, client, *subscription))
+	}
+
+	if *oidcEmail != "" {
+		ra.Verifier = &receiveadapter.TokenVerifier{
+			Keys:     &receiveadapter.JWKSKeySource{URL: receiveadapter.GoogleCertsURL},
+			Email:    *oidcEmail,
+			Audience: *oidcAudience,
+		}
+		log.Printf("Requiring OIDC tokens of %q", *oidcEmail)
+	}
+
 	port := os.Getenv(envPort)
 	if port == "" {
 		port = "8080"
@@ -46,9 +147,5 @@ func main() {
 
 	log.Printf("Sink is: %q", *sink)
 
-	ra := &receiveadapter.CloudSchedulerReceiveAdapter{
-		Sink: *sink,
-	}
-
 	http.ListenAndServe(":8080", ra)
 }
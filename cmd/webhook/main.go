@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/knative/pkg/logging"
+	"github.com/knative/pkg/signals"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+	"github.com/vaikas-google/csr/pkg/webhook"
+)
+
+var (
+	masterURL  = flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	kubeconfig = flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	namespace  = flag.String("namespace", "cloudschedulersource-system", "The namespace of the webhook service and of its certificates secret.")
+	port       = flag.Int("port", 8443, "The port to serve the webhook on.")
+	// Must match the -backend flag of the controller.
+	defaultBackend = flag.String("backend", string(v1alpha1.SchedulerBackendCloudScheduler), "The scheduler backend for sources that don't specify one, CloudScheduler, InCluster or CronJob.")
+)
+
+func main() {
+	flag.Parse()
+
+	// set up signals so we handle the first shutdown signal gracefully
+	stopCh := signals.SetupSignalHandler()
+
+	logger := logging.FromContext(context.TODO()).Named("webhook")
+
+	switch backend := v1alpha1.SchedulerBackend(*defaultBackend); backend {
+	case v1alpha1.SchedulerBackendCloudScheduler, v1alpha1.SchedulerBackendInCluster, v1alpha1.SchedulerBackendCronJob:
+		v1alpha1.DefaultBackend = backend
+	default:
+		logger.Fatalf("Unknown scheduler backend: %q", *defaultBackend)
+	}
+
+	cfg, err := clientcmd.BuildConfigFromFlags(*masterURL, *kubeconfig)
+	if err != nil {
+		logger.Fatalf("Error building kubeconfig: %s", err.Error())
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		logger.Fatalf("Error building kubernetes clientset: %s", err.Error())
+	}
+
+	options := webhook.ControllerOptions{
+		WebhookName:       "webhook.cloudschedulersource.sources.aikas.org",
+		ServiceName:       "cloudschedulersource-webhook",
+		Namespace:         *namespace,
+		SecretName:        "cloudschedulersource-webhook-certs",
+		Port:              *port,
+		RegistrationDelay: time.Second * 2,
+	}
+	controller := webhook.AdmissionController{
+		Client:  kubeClient,
+		Options: options,
+		Handlers: map[schema.GroupVersionKind]webhook.GenericCRD{
+			v1alpha1.SchemeGroupVersion.WithKind("CloudSchedulerSource"): &v1alpha1.CloudSchedulerSource{},
+		},
+		Logger: logger,
+	}
+	if err := controller.Run(stopCh); err != nil {
+		logger.Fatalf("Error running webhook: %s", err.Error())
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultTimeZone is the time zone of sources that don't specify one.
+	DefaultTimeZone = "UTC"
+
+	// DefaultHTTPMethod is the HTTP method of sources that don't specify one.
+	DefaultHTTPMethod = "POST"
+
+	// DefaultServiceAccountName is the service account of sources that don't
+	// specify one.
+	DefaultServiceAccountName = "default"
+
+	// DefaultRelativeURI is the relative URI of App Engine targets that
+	// don't specify one.
+	DefaultRelativeURI = "/"
+
+	// DefaultCloudEventsSpecVersion is the CloudEvents spec version of
+	// sources that don't specify one.
+	DefaultCloudEventsSpecVersion = "0.1"
+
+	// DefaultCloudEventsEncoding is the CloudEvents encoding of sources that
+	// don't specify one.
+	DefaultCloudEventsEncoding = CloudEventsEncodingBinary
+
+	// DefaultDeduplicationCacheSize is how many events sources with
+	// deduplication remember if they don't specify it.
+	DefaultDeduplicationCacheSize = 1000
+
+	// DefaultDeduplicationTTL is how long sources with deduplication
+	// remember events for if they don't specify it.
+	DefaultDeduplicationTTL = time.Hour
+)
+
+// SetDefaults implements apis.Defaultable.
+func (csr *CloudSchedulerSource) SetDefaults() {
+	csr.Spec.SetDefaults()
+}
+
+// SetDefaults sets the defaults of the unset fields of the spec.
+func (s *CloudSchedulerSourceSpec) SetDefaults() {
+	if s.TimeZone == "" {
+		s.TimeZone = DefaultTimeZone
+	}
+	if s.HTTPMethod == "" {
+		s.HTTPMethod = DefaultHTTPMethod
+	}
+	if s.ServiceAccountName == "" {
+		s.ServiceAccountName = DefaultServiceAccountName
+	}
+	if s.AppEngineTarget != nil && s.AppEngineTarget.RelativeURI == "" {
+		s.AppEngineTarget.RelativeURI = DefaultRelativeURI
+	}
+	if s.CloudEvents != nil {
+		if s.CloudEvents.SpecVersion == "" {
+			s.CloudEvents.SpecVersion = DefaultCloudEventsSpecVersion
+		}
+		if s.CloudEvents.Encoding == "" {
+			s.CloudEvents.Encoding = DefaultCloudEventsEncoding
+		}
+	}
+	if s.Deduplication != nil {
+		if s.Deduplication.CacheSize == 0 {
+			s.Deduplication.CacheSize = DefaultDeduplicationCacheSize
+		}
+		if s.Deduplication.TTL == nil {
+			s.Deduplication.TTL = &metav1.Duration{Duration: DefaultDeduplicationTTL}
+		}
+	}
+}
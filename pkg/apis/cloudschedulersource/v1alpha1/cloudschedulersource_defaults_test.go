@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		spec CloudSchedulerSourceSpec
+		want CloudSchedulerSourceSpec
+	}{{
+		name: "empty",
+		want: CloudSchedulerSourceSpec{
+			TimeZone:           "UTC",
+			HTTPMethod:         "POST",
+			ServiceAccountName: "default",
+		},
+	}, {
+		name: "already set",
+		spec: CloudSchedulerSourceSpec{
+			TimeZone:           "Europe/Helsinki",
+			HTTPMethod:         "GET",
+			ServiceAccountName: "scheduler",
+		},
+		want: CloudSchedulerSourceSpec{
+			TimeZone:           "Europe/Helsinki",
+			HTTPMethod:         "GET",
+			ServiceAccountName: "scheduler",
+		},
+	}, {
+		name: "cloud events format",
+		spec: CloudSchedulerSourceSpec{
+			CloudEvents: &CloudEventsFormat{},
+		},
+		want: CloudSchedulerSourceSpec{
+			TimeZone:           "UTC",
+			HTTPMethod:         "POST",
+			ServiceAccountName: "default",
+			CloudEvents: &CloudEventsFormat{
+				SpecVersion: "0.1",
+				Encoding:    CloudEventsEncodingBinary,
+			},
+		},
+	}, {
+		name: "deduplication",
+		spec: CloudSchedulerSourceSpec{
+			Deduplication: &Deduplication{},
+		},
+		want: CloudSchedulerSourceSpec{
+			TimeZone:           "UTC",
+			HTTPMethod:         "POST",
+			ServiceAccountName: "default",
+			Deduplication: &Deduplication{
+				CacheSize: 1000,
+				TTL:       &metav1.Duration{Duration: time.Hour},
+			},
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			csr := &CloudSchedulerSource{Spec: tc.spec}
+			csr.SetDefaults()
+			if diff := cmp.Diff(tc.want, csr.Spec); diff != "" {
+				t.Errorf("SetDefaults() (-want +got): %s", diff)
+			}
+		})
+	}
+}
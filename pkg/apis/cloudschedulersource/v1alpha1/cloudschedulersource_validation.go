@@ -0,0 +1,518 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/pkg/apis"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vaikas-google/csr/pkg/cron"
+)
+
+// httpMethods are the HTTP methods a source can call the Receive Adapter
+// with, and whether the call may have a body.
+var httpMethods = map[string]bool{
+	"GET":  false,
+	"POST": true,
+	"PUT":  true,
+}
+
+// Validate implements apis.Validatable.
+func (csr *CloudSchedulerSource) Validate() *apis.FieldError {
+	return csr.Spec.Validate().ViaField("spec")
+}
+
+// Validate validates the spec of a CloudSchedulerSource.
+func (s *CloudSchedulerSourceSpec) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+
+	switch s.Backend {
+	case "", SchedulerBackendCloudScheduler, SchedulerBackendInCluster, SchedulerBackendCronJob:
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(string(s.Backend), "backend"))
+	}
+	// Sources without a backend must only use the features of the default.
+	backend := s.effectiveBackend()
+
+	if s.Schedule == "" {
+		errs = errs.Also(apis.ErrMissingField("schedule"))
+	} else if _, err := cron.Parse(s.Schedule); err != nil {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value %q", s.Schedule),
+			Paths:   []string{"schedule"},
+			Details: err.Error(),
+		})
+	} else if backend == SchedulerBackendCronJob && cron.IsInterval(s.Schedule) {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value %q", s.Schedule),
+			Paths:   []string{"schedule"},
+			Details: "intervals are not supported by the CronJob backend",
+		})
+	}
+
+	if s.TimeZone != "" {
+		// "Local" is accepted by time.LoadLocation, but isn't an IANA name.
+		if _, err := time.LoadLocation(s.TimeZone); err != nil || s.TimeZone == "Local" {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("invalid value %q", s.TimeZone),
+				Paths:   []string{"timezone"},
+				Details: "must be an IANA time zone, for example America/New_York",
+			})
+		} else if backend == SchedulerBackendCronJob && s.TimeZone != "UTC" {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("invalid value %q", s.TimeZone),
+				Paths:   []string{"timezone"},
+				Details: "the CronJob backend only supports UTC",
+			})
+		}
+	}
+
+	if s.HTTPMethod != "" {
+		if withBody, ok := httpMethods[s.HTTPMethod]; !ok {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("invalid value %q", s.HTTPMethod),
+				Paths:   []string{"httpMethod"},
+				Details: "must be one of GET, POST or PUT",
+			})
+		} else if !withBody && s.Body != "" {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("body is not allowed with httpMethod %s", s.HTTPMethod),
+				Paths:   []string{"body"},
+			})
+		}
+	}
+
+	if s.RetryConfig != nil {
+		errs = errs.Also(s.RetryConfig.Validate().ViaField("retryConfig"))
+	}
+
+	if s.CloudEvents != nil {
+		if s.AppEngineTarget != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "cloudEvents is not allowed with appEngineTarget",
+				Paths:   []string{"cloudEvents"},
+			})
+		}
+		errs = errs.Also(s.CloudEvents.Validate().ViaField("cloudEvents"))
+	}
+
+	if s.SinkRetry != nil {
+		errs = errs.Also(s.SinkRetry.Validate().ViaField("sinkRetry"))
+	}
+
+	if s.Deduplication != nil {
+		switch {
+		case backend == SchedulerBackendCronJob:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("deduplication is not supported by the %s backend", backend),
+				Paths:   []string{"deduplication"},
+			})
+		case s.AppEngineTarget != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "deduplication is not allowed with appEngineTarget",
+				Paths:   []string{"deduplication"},
+			})
+		}
+		errs = errs.Also(s.Deduplication.Validate().ViaField("deduplication"))
+	}
+
+	if len(s.Headers) > 0 || len(s.ForwardHeaders) > 0 {
+		switch {
+		case backend == SchedulerBackendCronJob:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("headers is not supported by the %s backend", backend),
+				Paths:   []string{"headers"},
+			})
+		case s.PubSubTarget != nil || s.AppEngineTarget != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("headers is not allowed with %s", s.targetKind()),
+				Paths:   []string{"headers"},
+			})
+		}
+		errs = errs.Also(validateHeaders(s.Headers, s.ForwardHeaders))
+	}
+
+	if s.PubSubTarget != nil {
+		switch backend {
+		case SchedulerBackendInCluster, SchedulerBackendCronJob:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("pubsubTarget is not supported by the %s backend", backend),
+				Paths:   []string{"pubsubTarget"},
+			})
+		}
+		errs = errs.Also(s.PubSubTarget.Validate().ViaField("pubsubTarget"))
+		// Pub/Sub doesn't accept messages without data or attributes.
+		if s.Body == "" && len(s.PubSubTarget.Attributes) == 0 {
+			errs = errs.Also(apis.ErrMissingOneOf("body", "pubsubTarget.attributes"))
+		}
+	}
+
+	if s.AppEngineTarget != nil {
+		switch backend {
+		case SchedulerBackendInCluster, SchedulerBackendCronJob:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("appEngineTarget is not supported by the %s backend", backend),
+				Paths:   []string{"appEngineTarget"},
+			})
+		}
+		if s.PubSubTarget != nil {
+			errs = errs.Also(apis.ErrMultipleOneOf("appEngineTarget", "pubsubTarget"))
+		}
+		errs = errs.Also(s.AppEngineTarget.Validate().ViaField("appEngineTarget"))
+	}
+
+	if s.OIDCToken != nil {
+		switch {
+		case backend == SchedulerBackendInCluster || backend == SchedulerBackendCronJob:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("oidcToken is not supported by the %s backend", backend),
+				Paths:   []string{"oidcToken"},
+			})
+		case s.PubSubTarget != nil || s.AppEngineTarget != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("oidcToken is not allowed with %s", s.targetKind()),
+				Paths:   []string{"oidcToken"},
+			})
+		}
+		errs = errs.Also(s.OIDCToken.Validate().ViaField("oidcToken"))
+	}
+
+	switch {
+	case s.AppEngineTarget != nil:
+		// App Engine is called directly, without a sink.
+		if s.Sink != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "sink is not allowed with appEngineTarget",
+				Paths:   []string{"sink"},
+			})
+		}
+	case s.Sink == nil:
+		errs = errs.Also(apis.ErrMissingField("sink"))
+	default:
+		if fe := validateObjectReference(s.Sink); fe != nil {
+			errs = errs.Also(fe.ViaField("sink"))
+		}
+	}
+
+	if s.DeadLetterSink != nil {
+		if s.AppEngineTarget != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "deadLetterSink is not allowed with appEngineTarget",
+				Paths:   []string{"deadLetterSink"},
+			})
+		} else if fe := validateObjectReference(s.DeadLetterSink); fe != nil {
+			errs = errs.Also(fe.ViaField("deadLetterSink"))
+		}
+	}
+
+	return errs
+}
+
+// effectiveBackend returns the backend that runs the job of a source with
+// the spec, DefaultBackend if it doesn't select one.
+func (s *CloudSchedulerSourceSpec) effectiveBackend() SchedulerBackend {
+	if s.Backend == "" {
+		return DefaultBackend
+	}
+	return s.Backend
+}
+
+// targetKind returns the kind of target the job of a source with the spec
+// has.
+func (s *CloudSchedulerSourceSpec) targetKind() string {
+	switch {
+	case s.PubSubTarget != nil:
+		return "pubsubTarget"
+	case s.AppEngineTarget != nil:
+		return "appEngineTarget"
+	default:
+		return "http"
+	}
+}
+
+// Validate validates the retry configuration of a CloudSchedulerSource.
+func (rc *RetryConfig) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if rc.RetryCount < 0 || rc.RetryCount > 5 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(fmt.Sprint(rc.RetryCount), "0", "5", "retryCount"))
+	}
+	if rc.MaxDoublings < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprint(rc.MaxDoublings), "maxDoublings"))
+	}
+	for name, d := range map[string]*metav1.Duration{
+		"maxRetryDuration":   rc.MaxRetryDuration,
+		"minBackoffDuration": rc.MinBackoffDuration,
+		"maxBackoffDuration": rc.MaxBackoffDuration,
+	} {
+		if d != nil && d.Duration < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(d.Duration.String(), name))
+		}
+	}
+	if rc.MinBackoffDuration != nil && rc.MaxBackoffDuration != nil &&
+		rc.MinBackoffDuration.Duration > rc.MaxBackoffDuration.Duration {
+		errs = errs.Also(&apis.FieldError{
+			Message: "minBackoffDuration must not be longer than maxBackoffDuration",
+			Paths:   []string{"minBackoffDuration", "maxBackoffDuration"},
+		})
+	}
+	return errs
+}
+
+// Validate validates the CloudEvents format of a CloudSchedulerSource.
+func (f *CloudEventsFormat) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	switch f.SpecVersion {
+	case "", "0.1", "0.2", "1.0":
+	default:
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value %q", f.SpecVersion),
+			Paths:   []string{"specVersion"},
+			Details: "must be one of 0.1, 0.2 or 1.0",
+		})
+	}
+	switch f.Encoding {
+	case "", CloudEventsEncodingBinary, CloudEventsEncodingStructured:
+	default:
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value %q", f.Encoding),
+			Paths:   []string{"encoding"},
+			Details: "must be Binary or Structured",
+		})
+	}
+	// Both end up in headers in binary mode.
+	if strings.ContainsAny(f.Type, " \t\r\n") {
+		errs = errs.Also(apis.ErrInvalidValue(f.Type, "type"))
+	}
+	if _, err := url.Parse(f.Source); err != nil || strings.ContainsAny(f.Source, " \t\r\n") {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value %q", f.Source),
+			Paths:   []string{"source"},
+			Details: "must be a URI reference",
+		})
+	}
+	return errs
+}
+
+// Validate validates the deduplication configuration of a
+// CloudSchedulerSource.
+func (d *Deduplication) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if d.CacheSize < 1 || d.CacheSize > 100000 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(fmt.Sprint(d.CacheSize), "1", "100000", "cacheSize"))
+	}
+	if d.TTL != nil && d.TTL.Duration < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(d.TTL.Duration.String(), "ttl"))
+	}
+	return errs
+}
+
+// Validate validates the sink retry configuration of a CloudSchedulerSource.
+func (sr *SinkRetry) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if sr.MaxAttempts < 0 || sr.MaxAttempts > 10 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(fmt.Sprint(sr.MaxAttempts), "1", "10", "maxAttempts"))
+	}
+	for name, d := range map[string]*metav1.Duration{
+		"minBackoffDuration": sr.MinBackoffDuration,
+		"maxBackoffDuration": sr.MaxBackoffDuration,
+	} {
+		if d != nil && d.Duration < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(d.Duration.String(), name))
+		}
+	}
+	if sr.MinBackoffDuration != nil && sr.MaxBackoffDuration != nil &&
+		sr.MinBackoffDuration.Duration > sr.MaxBackoffDuration.Duration {
+		errs = errs.Also(&apis.FieldError{
+			Message: "minBackoffDuration must not be longer than maxBackoffDuration",
+			Paths:   []string{"minBackoffDuration", "maxBackoffDuration"},
+		})
+	}
+	if sr.JitterPercent != nil && (*sr.JitterPercent < 0 || *sr.JitterPercent > 100) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(fmt.Sprint(*sr.JitterPercent), "0", "100", "jitterPercent"))
+	}
+	for i, code := range sr.RetryableStatusCodes {
+		if code < 400 || code > 599 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(fmt.Sprint(code), "400", "599", fmt.Sprintf("retryableStatusCodes[%d]", i)))
+		}
+	}
+	return errs
+}
+
+// Validate validates the Pub/Sub target of a CloudSchedulerSource.
+func (t *PubSubTarget) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	for k := range t.Attributes {
+		// Pub/Sub reserves the attributes starting with goog.
+		if k == "" || strings.HasPrefix(strings.ToLower(k), "goog") {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, "attributes"))
+		}
+	}
+	if t.CredentialsSecret != nil {
+		if t.CredentialsSecret.Name == "" {
+			errs = errs.Also(apis.ErrMissingField("credentialsSecret.name"))
+		}
+		if t.CredentialsSecret.Key == "" {
+			errs = errs.Also(apis.ErrMissingField("credentialsSecret.key"))
+		}
+	}
+	return errs
+}
+
+// Validate validates the App Engine target of a CloudSchedulerSource.
+func (t *AppEngineTarget) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if t.RelativeURI != "" && !strings.HasPrefix(t.RelativeURI, "/") {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value %q", t.RelativeURI),
+			Paths:   []string{"relativeUri"},
+			Details: `must start with "/"`,
+		})
+	}
+	for k := range t.Headers {
+		if k == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, "headers"))
+		}
+	}
+	return errs
+}
+
+// reservedHeaderPrefixes are the prefixes of the headers that are set by
+// Cloud Scheduler, or that would interfere with the calls.
+var reservedHeaderPrefixes = []string{
+	"Authorization",
+	"Content-Length",
+	"Host",
+	"X-Appengine-",
+	"X-Cloudscheduler",
+	"X-Google-",
+}
+
+// validateHeaders checks that the headers of the calls of a job can be set,
+// and that the forwarded headers are among them.
+func validateHeaders(headers map[string]string, forward []string) *apis.FieldError {
+	var errs *apis.FieldError
+	for k := range headers {
+		if k == "" || strings.ContainsAny(k, " :\t") {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, "headers"))
+			continue
+		}
+		canonical := http.CanonicalHeaderKey(k)
+		for _, prefix := range reservedHeaderPrefixes {
+			if strings.HasPrefix(canonical, prefix) {
+				errs = errs.Also(apis.ErrInvalidKeyName(k, "headers", "the header is reserved"))
+				break
+			}
+		}
+	}
+	for i, name := range forward {
+		found := false
+		for k := range headers {
+			if strings.EqualFold(k, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("invalid value %q", name),
+				Paths:   []string{fmt.Sprintf("forwardHeaders[%d]", i)},
+				Details: "must be one of the headers",
+			})
+		}
+	}
+	return errs
+}
+
+// Validate validates the OIDC token configuration of a CloudSchedulerSource.
+func (t *OIDCToken) Validate() *apis.FieldError {
+	if t.ServiceAccountEmail == "" {
+		return apis.ErrMissingField("serviceAccountEmail")
+	}
+	if !strings.Contains(t.ServiceAccountEmail, "@") {
+		return apis.ErrInvalidValue(t.ServiceAccountEmail, "serviceAccountEmail")
+	}
+	return nil
+}
+
+// validateObjectReference checks that a reference to an object in the same
+// namespace as the source has everything needed to resolve it.
+func validateObjectReference(ref *corev1.ObjectReference) *apis.FieldError {
+	var errs *apis.FieldError
+	if ref.APIVersion == "" {
+		errs = errs.Also(apis.ErrMissingField("apiVersion"))
+	}
+	if ref.Kind == "" {
+		errs = errs.Also(apis.ErrMissingField("kind"))
+	}
+	if ref.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name"))
+	}
+	// Sinks are always looked up in the namespace of the source.
+	if ref.Namespace != "" {
+		errs = errs.Also(apis.ErrDisallowedFields("namespace"))
+	}
+	return errs
+}
+
+// CheckImmutableFields implements apis.Immutable. The project, location and
+// backend decide where the job lives, so changing them would leave the
+// existing job behind. Likewise for the kind of target of the job, which
+// decides what the Receive Adapter runs as, if anything.
+func (csr *CloudSchedulerSource) CheckImmutableFields(og apis.Immutable) *apis.FieldError {
+	if og == nil {
+		return nil
+	}
+	original, ok := og.(*CloudSchedulerSource)
+	if !ok {
+		return &apis.FieldError{Message: "The provided original was not a CloudSchedulerSource"}
+	}
+
+	type immutableFields struct {
+		GoogleCloudProject string
+		Location           string
+		Backend            SchedulerBackend
+		Target             string
+	}
+	before := immutableFields{
+		GoogleCloudProject: original.Spec.GoogleCloudProject,
+		Location:           original.Spec.Location,
+		Backend:            original.Spec.effectiveBackend(),
+		Target:             original.Spec.targetKind(),
+	}
+	after := immutableFields{
+		GoogleCloudProject: csr.Spec.GoogleCloudProject,
+		Location:           csr.Spec.Location,
+		Backend:            csr.Spec.effectiveBackend(),
+		Target:             csr.Spec.targetKind(),
+	}
+	if diff := cmp.Diff(before, after); diff != "" {
+		return &apis.FieldError{
+			Message: "Immutable fields changed (-old +new)",
+			Paths:   []string{"spec"},
+			Details: diff,
+		}
+	}
+	return nil
+}
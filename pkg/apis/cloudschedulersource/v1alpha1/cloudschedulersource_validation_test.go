@@ -0,0 +1,446 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func validSpec() CloudSchedulerSourceSpec {
+	return CloudSchedulerSourceSpec{
+		GoogleCloudProject: "project",
+		Location:           "us-central1",
+		Schedule:           "*/5 * * * *",
+		Sink: &corev1.ObjectReference{
+			APIVersion: "eventing.knative.dev/v1alpha1",
+			Kind:       "Channel",
+			Name:       "channel",
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec func(*CloudSchedulerSourceSpec)
+		// defaultBackend is the backend of the controller, CloudScheduler
+		// if empty.
+		defaultBackend SchedulerBackend
+		wantErr        string
+	}{{
+		name: "valid",
+		spec: func(*CloudSchedulerSourceSpec) {},
+	}, {
+		name: "valid with everything",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Schedule = "every 10 minutes"
+			s.TimeZone = "America/New_York"
+			s.HTTPMethod = "PUT"
+			s.Body = "hello"
+			s.Backend = SchedulerBackendInCluster
+		},
+	}, {
+		name:    "missing schedule",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Schedule = "" },
+		wantErr: "missing field(s): spec.schedule",
+	}, {
+		name:    "invalid schedule",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Schedule = "61 * * * *" },
+		wantErr: `invalid value "61 * * * *": spec.schedule`,
+	}, {
+		name:    "invalid time zone",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.TimeZone = "Mars/Olympus_Mons" },
+		wantErr: `invalid value "Mars/Olympus_Mons": spec.timezone`,
+	}, {
+		name:    "local time zone",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.TimeZone = "Local" },
+		wantErr: `invalid value "Local": spec.timezone`,
+	}, {
+		name:    "invalid method",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.HTTPMethod = "post" },
+		wantErr: `invalid value "post": spec.httpMethod`,
+	}, {
+		name: "body with GET",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.HTTPMethod = "GET"
+			s.Body = "hello"
+		},
+		wantErr: "body is not allowed with httpMethod GET: spec.body",
+	}, {
+		name:    "invalid backend",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Backend = "Cron" },
+		wantErr: `invalid value "Cron": spec.backend`,
+	}, {
+		name: "CronJob with time zone",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendCronJob
+			s.TimeZone = "Europe/Helsinki"
+		},
+		wantErr: `invalid value "Europe/Helsinki": spec.timezone`,
+	}, {
+		name: "CronJob with interval",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendCronJob
+			s.Schedule = "every 5 minutes"
+		},
+		wantErr: `invalid value "every 5 minutes": spec.schedule`,
+	}, {
+		name: "valid retry config",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.RetryConfig = &RetryConfig{
+				RetryCount:         3,
+				MaxRetryDuration:   &metav1.Duration{Duration: time.Hour},
+				MinBackoffDuration: &metav1.Duration{Duration: 10 * time.Second},
+				MaxBackoffDuration: &metav1.Duration{Duration: 5 * time.Minute},
+				MaxDoublings:       3,
+			}
+		},
+	}, {
+		name:    "too many retries",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.RetryConfig = &RetryConfig{RetryCount: 6} },
+		wantErr: "expected 0 <= 6 <= 5: spec.retryConfig.retryCount",
+	}, {
+		name: "negative backoff",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.RetryConfig = &RetryConfig{MinBackoffDuration: &metav1.Duration{Duration: -time.Second}}
+		},
+		wantErr: `invalid value "-1s": spec.retryConfig.minBackoffDuration`,
+	}, {
+		name: "backoffs swapped",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.RetryConfig = &RetryConfig{
+				MinBackoffDuration: &metav1.Duration{Duration: time.Minute},
+				MaxBackoffDuration: &metav1.Duration{Duration: time.Second},
+			}
+		},
+		wantErr: "minBackoffDuration must not be longer than maxBackoffDuration",
+	}, {
+		name: "valid cloud events format",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.CloudEvents = &CloudEventsFormat{SpecVersion: "1.0", Encoding: CloudEventsEncodingStructured}
+		},
+	}, {
+		name:    "unsupported cloud events version",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.CloudEvents = &CloudEventsFormat{SpecVersion: "0.3"} },
+		wantErr: `invalid value "0.3": spec.cloudEvents.specVersion`,
+	}, {
+		name: "custom event type and source",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.CloudEvents = &CloudEventsFormat{Type: "com.example.billing.nightly", Source: "/billing/nightly"}
+		},
+	}, {
+		name:    "invalid event source",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.CloudEvents = &CloudEventsFormat{Source: "billing nightly"} },
+		wantErr: `invalid value "billing nightly": spec.cloudEvents.source`,
+	}, {
+		name: "valid deduplication",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Deduplication = &Deduplication{CacheSize: 100, TTL: &metav1.Duration{Duration: 24 * time.Hour}}
+		},
+	}, {
+		name:    "deduplication cache too large",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Deduplication = &Deduplication{CacheSize: 1000000} },
+		wantErr: "expected 1 <= 1000000 <= 100000: spec.deduplication.cacheSize",
+	}, {
+		name:    "deduplication cache empty",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Deduplication = &Deduplication{CacheSize: 0} },
+		wantErr: "expected 1 <= 0 <= 100000: spec.deduplication.cacheSize",
+	}, {
+		name: "smallest deduplication cache",
+		spec: func(s *CloudSchedulerSourceSpec) { s.Deduplication = &Deduplication{CacheSize: 1} },
+	}, {
+		name: "largest deduplication cache",
+		spec: func(s *CloudSchedulerSourceSpec) { s.Deduplication = &Deduplication{CacheSize: 100000} },
+	}, {
+		name: "deduplication with the CronJob backend",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendCronJob
+			s.Deduplication = &Deduplication{CacheSize: 100}
+		},
+		wantErr: "deduplication is not supported by the CronJob backend: spec.deduplication",
+	}, {
+		name: "valid sink retry",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			jitter := int32(0)
+			s.SinkRetry = &SinkRetry{
+				MaxAttempts:          5,
+				MinBackoffDuration:   &metav1.Duration{Duration: time.Second},
+				MaxBackoffDuration:   &metav1.Duration{Duration: 10 * time.Second},
+				JitterPercent:        &jitter,
+				RetryableStatusCodes: []int32{429, 500, 503},
+			}
+		},
+	}, {
+		name:    "too many attempts",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.SinkRetry = &SinkRetry{MaxAttempts: 11} },
+		wantErr: "expected 1 <= 11 <= 10: spec.sinkRetry.maxAttempts",
+	}, {
+		name:    "retrying success",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.SinkRetry = &SinkRetry{RetryableStatusCodes: []int32{503, 200}} },
+		wantErr: "expected 400 <= 200 <= 599: spec.sinkRetry.retryableStatusCodes[1]",
+	}, {
+		name: "valid headers",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Headers = map[string]string{"X-Team": "billing", "X-Region": "eu"}
+			s.ForwardHeaders = []string{"x-team"}
+		},
+	}, {
+		name:    "reserved header",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Headers = map[string]string{"x-cloudscheduler-jobname": "other"} },
+		wantErr: "invalid key name \"x-cloudscheduler-jobname\": spec.headers",
+	}, {
+		name: "forwarded header not set",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Headers = map[string]string{"X-Team": "billing"}
+			s.ForwardHeaders = []string{"X-Region"}
+		},
+		wantErr: `invalid value "X-Region": spec.forwardHeaders[0]`,
+	}, {
+		name: "headers with CronJob",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendCronJob
+			s.Headers = map[string]string{"X-Team": "billing"}
+		},
+		wantErr: "headers is not supported by the CronJob backend: spec.headers",
+	}, {
+		name:           "interval with the CronJob default",
+		spec:           func(s *CloudSchedulerSourceSpec) { s.Schedule = "every 5 mins" },
+		defaultBackend: SchedulerBackendCronJob,
+		wantErr:        "intervals are not supported by the CronJob backend",
+	}, {
+		name: "valid pubsub target",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.PubSubTarget = &PubSubTarget{
+				Attributes: map[string]string{"team": "billing"},
+				CredentialsSecret: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "google-cloud-key"},
+					Key:                  "key.json",
+				},
+			}
+		},
+	}, {
+		name: "pubsub target with InCluster",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendInCluster
+			s.PubSubTarget = &PubSubTarget{}
+			s.Body = "hello"
+		},
+		wantErr: "pubsubTarget is not supported by the InCluster backend: spec.pubsubTarget",
+	}, {
+		name: "pubsub target with the InCluster default",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.PubSubTarget = &PubSubTarget{}
+			s.Body = "hello"
+		},
+		defaultBackend: SchedulerBackendInCluster,
+		wantErr:        "pubsubTarget is not supported by the InCluster backend: spec.pubsubTarget",
+	}, {
+		name: "pubsub target with CloudScheduler and the InCluster default",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendCloudScheduler
+			s.PubSubTarget = &PubSubTarget{}
+			s.Body = "hello"
+		},
+		defaultBackend: SchedulerBackendInCluster,
+	}, {
+		name: "reserved pubsub attribute",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.PubSubTarget = &PubSubTarget{Attributes: map[string]string{"googClient": "x"}}
+		},
+		wantErr: "spec.pubsubTarget.attributes",
+	}, {
+		name: "incomplete credentials secret",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.PubSubTarget = &PubSubTarget{CredentialsSecret: &corev1.SecretKeySelector{Key: "key.json"}}
+			s.Body = "hello"
+		},
+		wantErr: "missing field(s): spec.pubsubTarget.credentialsSecret.name",
+	}, {
+		name:    "pubsub target without data",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.PubSubTarget = &PubSubTarget{} },
+		wantErr: "expected exactly one, got neither: spec.body, spec.pubsubTarget.attributes",
+	}, {
+		name: "valid app engine target",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Sink = nil
+			s.AppEngineTarget = &AppEngineTarget{
+				RelativeURI: "/tasks/cleanup?all=true",
+				Service:     "worker",
+				Version:     "v2",
+				Headers:     map[string]string{"X-Team": "billing"},
+			}
+		},
+	}, {
+		name: "app engine target with sink",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.AppEngineTarget = &AppEngineTarget{}
+		},
+		wantErr: "sink is not allowed with appEngineTarget: spec.sink",
+	}, {
+		name: "relative URI without slash",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Sink = nil
+			s.AppEngineTarget = &AppEngineTarget{RelativeURI: "tasks"}
+		},
+		wantErr: `invalid value "tasks": spec.appEngineTarget.relativeUri`,
+	}, {
+		name: "app engine and pubsub targets",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Sink = nil
+			s.Body = "hello"
+			s.AppEngineTarget = &AppEngineTarget{}
+			s.PubSubTarget = &PubSubTarget{}
+		},
+		wantErr: "expected exactly one, got both: spec.appEngineTarget, spec.pubsubTarget",
+	}, {
+		name: "valid oidc token",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.OIDCToken = &OIDCToken{ServiceAccountEmail: "scheduler@project.iam.gserviceaccount.com"}
+		},
+	}, {
+		name:    "oidc token without email",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.OIDCToken = &OIDCToken{Audience: "https://example.com/"} },
+		wantErr: "missing field(s): spec.oidcToken.serviceAccountEmail",
+	}, {
+		name: "oidc token with CronJob",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Backend = SchedulerBackendCronJob
+			s.OIDCToken = &OIDCToken{ServiceAccountEmail: "scheduler@project.iam.gserviceaccount.com"}
+		},
+		wantErr: "oidcToken is not supported by the CronJob backend: spec.oidcToken",
+	}, {
+		name: "oidc token with pubsub target",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.Body = "hello"
+			s.PubSubTarget = &PubSubTarget{}
+			s.OIDCToken = &OIDCToken{ServiceAccountEmail: "scheduler@project.iam.gserviceaccount.com"}
+		},
+		wantErr: "oidcToken is not allowed with pubsubTarget: spec.oidcToken",
+	}, {
+		name:    "missing sink",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Sink = nil },
+		wantErr: "missing field(s): spec.sink",
+	}, {
+		name:    "incomplete sink",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Sink = &corev1.ObjectReference{Name: "channel"} },
+		wantErr: "missing field(s): spec.sink.apiVersion, spec.sink.kind",
+	}, {
+		name:    "sink in another namespace",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Sink.Namespace = "other" },
+		wantErr: "must not set the field(s): spec.sink.namespace",
+	}, {
+		name: "valid dead letter sink",
+		spec: func(s *CloudSchedulerSourceSpec) {
+			s.DeadLetterSink = &corev1.ObjectReference{
+				APIVersion: "eventing.knative.dev/v1alpha1",
+				Kind:       "Channel",
+				Name:       "dead-letters",
+			}
+		},
+	}, {
+		name:    "incomplete dead letter sink",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.DeadLetterSink = &corev1.ObjectReference{Name: "dead-letters"} },
+		wantErr: "missing field(s): spec.deadLetterSink.apiVersion, spec.deadLetterSink.kind",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.defaultBackend != "" {
+				defer func(b SchedulerBackend) { DefaultBackend = b }(DefaultBackend)
+				DefaultBackend = tc.defaultBackend
+			}
+			csr := &CloudSchedulerSource{Spec: validSpec()}
+			tc.spec(&csr.Spec)
+			err := csr.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckImmutableFields(t *testing.T) {
+	tests := []struct {
+		name string
+		// original changes the spec of the original source, if set.
+		original func(*CloudSchedulerSourceSpec)
+		spec     func(*CloudSchedulerSourceSpec)
+		wantErr  bool
+	}{{
+		name: "schedule changed",
+		spec: func(s *CloudSchedulerSourceSpec) { s.Schedule = "@daily" },
+	}, {
+		name:    "project changed",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.GoogleCloudProject = "other" },
+		wantErr: true,
+	}, {
+		name:    "location changed",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Location = "europe-west1" },
+		wantErr: true,
+	}, {
+		name:    "backend changed",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.Backend = SchedulerBackendInCluster },
+		wantErr: true,
+	}, {
+		name:     "backend set to the default",
+		original: func(s *CloudSchedulerSourceSpec) { s.Backend = "" },
+		spec:     func(s *CloudSchedulerSourceSpec) { s.Backend = DefaultBackend },
+	}, {
+		name:     "default backend changed",
+		original: func(s *CloudSchedulerSourceSpec) { s.Backend = "" },
+		spec:     func(s *CloudSchedulerSourceSpec) { s.Backend = SchedulerBackendInCluster },
+		wantErr:  true,
+	}, {
+		name:    "pubsub target added",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.PubSubTarget = &PubSubTarget{} },
+		wantErr: true,
+	}, {
+		name:    "app engine target added",
+		spec:    func(s *CloudSchedulerSourceSpec) { s.AppEngineTarget = &AppEngineTarget{} },
+		wantErr: true,
+	}, {
+		name:     "app engine target changed",
+		original: func(s *CloudSchedulerSourceSpec) { s.AppEngineTarget = &AppEngineTarget{Service: "worker"} },
+		spec:     func(s *CloudSchedulerSourceSpec) { s.AppEngineTarget.Service = "other" },
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			original := &CloudSchedulerSource{Spec: validSpec()}
+			if tc.original != nil {
+				tc.original(&original.Spec)
+			}
+			csr := original.DeepCopy()
+			tc.spec(&csr.Spec)
+			err := csr.CheckImmutableFields(original)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("CheckImmutableFields() = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -64,24 +65,428 @@ type CloudSchedulerSourceSpec struct {
 	// +optional
 	Body string `json:"body,omitempty"`
 
+	// Headers are added to every call of the Receive Adapter. Not supported
+	// with a PubSubTarget or AppEngineTarget, or by the CronJob backend.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// ForwardHeaders are the names of the Headers the Receive Adapter adds
+	// to the events as CloudEvent extensions, so consumers can route on
+	// them. The extension names are the lowercased header names without
+	// dashes, for example X-Team becomes xteam.
+	// +optional
+	ForwardHeaders []string `json:"forwardHeaders,omitempty"`
+
+	// Backend selects what runs the schedule. If omitted, the default
+	// backend the controller was configured with is used.
+	// +optional
+	Backend SchedulerBackend `json:"backend,omitempty"`
+
+	// RetryConfig controls how failed calls are retried. If omitted, failed
+	// calls aren't retried until the next scheduled run. Only used by the
+	// CloudScheduler backend.
+	// +optional
+	RetryConfig *RetryConfig `json:"retryConfig,omitempty"`
+
+	// CloudEvents controls how the Receive Adapter encodes the events it
+	// sends. If omitted, CloudEvents 0.1 in binary mode. Not allowed with
+	// an AppEngineTarget.
+	// +optional
+	CloudEvents *CloudEventsFormat `json:"cloudEvents,omitempty"`
+
+	// SinkRetry controls how the Receive Adapter retries events the sink
+	// didn't accept, before reporting the failure to the job. If omitted,
+	// the Receive Adapter defaults are used.
+	// +optional
+	SinkRetry *SinkRetry `json:"sinkRetry,omitempty"`
+
+	// Deduplication makes the Receive Adapter drop the events it already
+	// delivered, such as those of calls retried after the sink accepted
+	// them, if set.
+	// +optional
+	Deduplication *Deduplication `json:"deduplication,omitempty"`
+
+	// PubSubTarget makes the job publish the events to a Pub/Sub topic
+	// created for the source, instead of calling the Receive Adapter over
+	// HTTP. The Receive Adapter then runs as a Deployment that pulls the
+	// events from a subscription to the topic, so it doesn't need to be
+	// reachable from the internet. Only supported by the CloudScheduler
+	// backend.
+	// +optional
+	PubSubTarget *PubSubTarget `json:"pubsubTarget,omitempty"`
+
+	// AppEngineTarget makes the job call an App Engine app of the
+	// GoogleCloudProject directly, instead of sending events to a sink, so
+	// there is no Receive Adapter and Sink must be omitted. HTTPMethod and
+	// Body are used for the calls. Only supported by the CloudScheduler
+	// backend.
+	// +optional
+	AppEngineTarget *AppEngineTarget `json:"appEngineTarget,omitempty"`
+
+	// OIDCToken makes the job call the Receive Adapter with an OIDC token
+	// of a Google service account, which the Receive Adapter verifies, so
+	// that only the job can send events through it. Not supported with a
+	// PubSubTarget or AppEngineTarget, which aren't called over HTTP by the
+	// job. Only supported by the CloudScheduler backend.
+	// +optional
+	OIDCToken *OIDCToken `json:"oidcToken,omitempty"`
+
+	// Suspend pauses the job, so no events are sent until it is unset.
+	// Deleting the source isn't needed to stop the schedule.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
 	// TODO: Add other configuration options here...
 
 	// Sink is a reference to an object that will resolve to a domain name to use
-	// as the sink.
+	// as the sink. Required unless the source has an AppEngineTarget.
 	// +optional
 	Sink *corev1.ObjectReference `json:"sink,omitempty"`
+
+	// DeadLetterSink is a reference to an object that will resolve to a
+	// domain name to send the events to that couldn't be delivered to the
+	// Sink, once the Receive Adapter gave up retrying. If omitted, such
+	// events are dropped. Not allowed with an AppEngineTarget.
+	// +optional
+	DeadLetterSink *corev1.ObjectReference `json:"deadLetterSink,omitempty"`
+}
+
+// RetryConfig controls how a Cloud Scheduler Job retries failed calls. The
+// fields left unset use the Cloud Scheduler defaults.
+type RetryConfig struct {
+	// RetryCount is how many times a failed call is retried, between 0
+	// and 5. Retries stop at the next scheduled run regardless.
+	// +optional
+	RetryCount int32 `json:"retryCount,omitempty"`
+
+	// MaxRetryDuration limits how long a failed call is retried for,
+	// measured from the first attempt. Unlimited if omitted.
+	// +optional
+	MaxRetryDuration *metav1.Duration `json:"maxRetryDuration,omitempty"`
+
+	// MinBackoffDuration is how long to wait before the first retry. If
+	// omitted, 5 seconds.
+	// +optional
+	MinBackoffDuration *metav1.Duration `json:"minBackoffDuration,omitempty"`
+
+	// MaxBackoffDuration is the longest to wait between retries. If
+	// omitted, 1 hour.
+	// +optional
+	MaxBackoffDuration *metav1.Duration `json:"maxBackoffDuration,omitempty"`
+
+	// MaxDoublings is how many times the wait between retries doubles
+	// before it increases linearly. If omitted or 0, 5.
+	// +optional
+	MaxDoublings int32 `json:"maxDoublings,omitempty"`
 }
 
+// CloudEventsFormat is the version of the CloudEvents spec and the encoding
+// the events of a source are sent with, and the attributes that identify
+// them.
+type CloudEventsFormat struct {
+	// SpecVersion is the version of the CloudEvents spec, 0.1, 0.2 or 1.0.
+	// If omitted, 0.1.
+	// +optional
+	SpecVersion string `json:"specVersion,omitempty"`
+
+	// Encoding is how the events are put in the requests to the sink. If
+	// omitted, Binary.
+	// +optional
+	Encoding CloudEventsEncoding `json:"encoding,omitempty"`
+
+	// Type is the type attribute of the events. If omitted,
+	// com.google.cloud.scheduler.job.execute.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Source is the source attribute of the events, a URI reference. If
+	// omitted, the full resource name of the Cloud Scheduler job, or the
+	// URI of the source when it has no such job.
+	// +optional
+	Source string `json:"source,omitempty"`
+}
+
+// CloudEventsEncoding is how events are put in HTTP requests.
+type CloudEventsEncoding string
+
+const (
+	// CloudEventsEncodingBinary puts the attributes of the events in
+	// headers, and their data in the body.
+	CloudEventsEncodingBinary CloudEventsEncoding = "Binary"
+
+	// CloudEventsEncodingStructured puts the whole events in the body as
+	// JSON.
+	CloudEventsEncodingStructured CloudEventsEncoding = "Structured"
+)
+
+// Deduplication controls how long the Receive Adapter remembers the events
+// it delivered. All the calls of a run of a job are the same event. Each
+// replica of the Receive Adapter remembers the events it delivered itself.
+type Deduplication struct {
+	// CacheSize is how many events are remembered at most, between 1 and
+	// 100000. If omitted, 1000.
+	// +optional
+	CacheSize int32 `json:"cacheSize,omitempty"`
+
+	// TTL is how long events are remembered for, or until the cache is
+	// full if zero. If omitted, 1 hour.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// SinkRetry controls how the Receive Adapter retries sending an event to the
+// sink. Retries stop early when the call of the job would time out. The
+// fields left unset use the Receive Adapter defaults.
+type SinkRetry struct {
+	// MaxAttempts is how many times an event is sent at most, between 1
+	// and 10. If omitted, 3.
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// MinBackoffDuration is how long to wait before the first retry. The
+	// wait doubles with every retry. If omitted, 1 second.
+	// +optional
+	MinBackoffDuration *metav1.Duration `json:"minBackoffDuration,omitempty"`
+
+	// MaxBackoffDuration is the longest to wait between retries. If
+	// omitted, 30 seconds.
+	// +optional
+	MaxBackoffDuration *metav1.Duration `json:"maxBackoffDuration,omitempty"`
+
+	// JitterPercent is how much, in percent, the waits are randomly made
+	// shorter or longer, so that retries of many events don't line up.
+	// If omitted, 20.
+	// +optional
+	JitterPercent *int32 `json:"jitterPercent,omitempty"`
+
+	// RetryableStatusCodes are the statuses of the responses of the sink
+	// to retry, in addition to failures to reach it. If omitted, 429, 502,
+	// 503 and 504. The Retry-After header of the responses is honored.
+	// +optional
+	RetryableStatusCodes []int32 `json:"retryableStatusCodes,omitempty"`
+}
+
+// PubSubTarget configures a job that publishes to a Pub/Sub topic. The topic
+// and its subscription are created in the GoogleCloudProject of the source,
+// and deleted along with it.
+type PubSubTarget struct {
+	// Attributes are added to every message the job publishes.
+	// +optional
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// CredentialsSecret is the key of a secret holding the Google Cloud
+	// service account key the Receive Adapter pulls with. If omitted, the
+	// Receive Adapter uses the Application Default Credentials.
+	// +optional
+	CredentialsSecret *corev1.SecretKeySelector `json:"credentialsSecret,omitempty"`
+}
+
+// AppEngineTarget configures a job that calls an App Engine app. The fields
+// of the routing left unset use the defaults of the app.
+type AppEngineTarget struct {
+	// RelativeURI is the path, and optionally the query, to call. It must
+	// start with "/". If omitted, "/".
+	// +optional
+	RelativeURI string `json:"relativeUri,omitempty"`
+
+	// Service is the App Engine service to call.
+	// +optional
+	Service string `json:"service,omitempty"`
+
+	// Version is the version of the service to call.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Instance is the instance of the version to call.
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// Headers are added to every call.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// OIDCToken configures the OIDC token a job authenticates its calls to the
+// Receive Adapter with.
+type OIDCToken struct {
+	// ServiceAccountEmail is the email of the Google service account the
+	// token is issued for. The service account of Cloud Scheduler must be
+	// allowed to act as it.
+	ServiceAccountEmail string `json:"serviceAccountEmail"`
+
+	// Audience the token is issued for. If omitted, the URL of the Receive
+	// Adapter.
+	// +optional
+	Audience string `json:"audience,omitempty"`
+}
+
+// SchedulerBackend is the kind of scheduler that runs the jobs of a
+// CloudSchedulerSource.
+type SchedulerBackend string
+
+const (
+	// SchedulerBackendCloudScheduler runs the job in Google Cloud Scheduler.
+	SchedulerBackendCloudScheduler SchedulerBackend = "CloudScheduler"
+
+	// SchedulerBackendInCluster runs the job from within the controller, so
+	// no Google Cloud project is needed. GoogleCloudProject and Location are
+	// ignored.
+	SchedulerBackendInCluster SchedulerBackend = "InCluster"
+
+	// SchedulerBackendCronJob runs the job as a Kubernetes CronJob whose pods
+	// send the events directly to the sink. GoogleCloudProject, Location and
+	// HTTPMethod are ignored, and TimeZone must be UTC.
+	SchedulerBackendCronJob SchedulerBackend = "CronJob"
+)
+
+// DefaultBackend is the backend of the sources that don't select one. The
+// controller and the webhook set it from their -backend flags, so that the
+// features the default backend doesn't support are rejected for those
+// sources as well.
+var DefaultBackend = SchedulerBackendCloudScheduler
+
+const (
+	// CloudSchedulerSourceConditionReady has status True when the
+	// CloudSchedulerSource is ready to send events.
+	CloudSchedulerSourceConditionReady = duckv1alpha1.ConditionReady
+
+	// CloudSchedulerSourceConditionSinkProvided has status True when the
+	// CloudSchedulerSource has been configured with a sink target.
+	CloudSchedulerSourceConditionSinkProvided duckv1alpha1.ConditionType = "SinkProvided"
+
+	// CloudSchedulerSourceConditionDeadLetterSinkProvided has status True
+	// when the DeadLetterSink of the CloudSchedulerSource has resolved, or
+	// when it doesn't have one.
+	CloudSchedulerSourceConditionDeadLetterSinkProvided duckv1alpha1.ConditionType = "DeadLetterSinkProvided"
+
+	// CloudSchedulerSourceConditionServiceReady has status True when the
+	// Receive Adapter Service has been created and has a domain.
+	CloudSchedulerSourceConditionServiceReady duckv1alpha1.ConditionType = "ServiceReady"
+
+	// CloudSchedulerSourceConditionJobReady has status True when the
+	// Cloud Scheduler Job has been created or updated to match the spec.
+	CloudSchedulerSourceConditionJobReady duckv1alpha1.ConditionType = "JobReady"
+)
+
+var cloudSchedulerSourceCondSet = duckv1alpha1.NewLivingConditionSet(
+	CloudSchedulerSourceConditionSinkProvided,
+	CloudSchedulerSourceConditionDeadLetterSinkProvided,
+	CloudSchedulerSourceConditionServiceReady,
+	CloudSchedulerSourceConditionJobReady)
+
 // CloudSchedulerSourceStatus is the status for a CloudSchedulerSource resource
 type CloudSchedulerSourceStatus struct {
-	// TODO: add conditions and other stuff here...
+	// Conditions holds the state of a source at a point in time.
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions duckv1alpha1.Conditions `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
 	// Job is the URI for the created Cloud Scheduler Job
 	Job string `json:"job"`
 
+	// JobState is the state of the job as reported by the backend, one of
+	// ENABLED, PAUSED, DISABLED or UPDATE_FAILED.
+	// +optional
+	JobState string `json:"jobState,omitempty"`
+
 	// SinkURI is the current active sink URI that has been configured
 	// for the CloudSchedulerSource
 	// +optional
 	SinkURI string `json:"sinkUri,omitempty"`
+
+	// DeadLetterSinkURI is the URI the DeadLetterSink resolved to, if the
+	// source has one.
+	// +optional
+	DeadLetterSinkURI string `json:"deadLetterSinkUri,omitempty"`
+
+	// EventType and EventSource are the type and source attributes of the
+	// events of the source, which consumers can filter on.
+	// +optional
+	EventType   string `json:"eventType,omitempty"`
+	EventSource string `json:"eventSource,omitempty"`
+
+	// Topic is the Pub/Sub topic the job publishes to, if it has a
+	// PubSubTarget.
+	// +optional
+	Topic string `json:"topic,omitempty"`
+}
+
+// GetCondition returns the condition currently associated with the given type, or nil.
+func (s *CloudSchedulerSourceStatus) GetCondition(t duckv1alpha1.ConditionType) *duckv1alpha1.Condition {
+	return cloudSchedulerSourceCondSet.Manage(s).GetCondition(t)
+}
+
+// IsReady returns true if the resource is ready overall.
+func (s *CloudSchedulerSourceStatus) IsReady() bool {
+	return cloudSchedulerSourceCondSet.Manage(s).IsHappy()
+}
+
+// InitializeConditions sets relevant unset conditions to Unknown state.
+func (s *CloudSchedulerSourceStatus) InitializeConditions() {
+	cloudSchedulerSourceCondSet.Manage(s).InitializeConditions()
+}
+
+// MarkSink sets the condition that the source has a sink configured.
+func (s *CloudSchedulerSourceStatus) MarkSink(uri string) {
+	s.SinkURI = uri
+	if len(uri) > 0 {
+		cloudSchedulerSourceCondSet.Manage(s).MarkTrue(CloudSchedulerSourceConditionSinkProvided)
+	} else {
+		cloudSchedulerSourceCondSet.Manage(s).MarkUnknown(CloudSchedulerSourceConditionSinkProvided, "SinkEmpty", "Sink has resolved to empty.")
+	}
+}
+
+// MarkSinkNotRequired sets the condition that the source has what it needs
+// to send events, for sources that don't have a sink, such as those with an
+// AppEngineTarget.
+func (s *CloudSchedulerSourceStatus) MarkSinkNotRequired() {
+	s.SinkURI = ""
+	s.DeadLetterSinkURI = ""
+	cloudSchedulerSourceCondSet.Manage(s).MarkTrue(CloudSchedulerSourceConditionSinkProvided)
+	cloudSchedulerSourceCondSet.Manage(s).MarkTrue(CloudSchedulerSourceConditionDeadLetterSinkProvided)
+}
+
+// MarkNoSink sets the condition that the source does not have a sink configured.
+func (s *CloudSchedulerSourceStatus) MarkNoSink(reason, messageFormat string, messageA ...interface{}) {
+	cloudSchedulerSourceCondSet.Manage(s).MarkFalse(CloudSchedulerSourceConditionSinkProvided, reason, messageFormat, messageA...)
+}
+
+// MarkDeadLetterSink sets the condition that the source's dead letter sink,
+// if it has one, has resolved to the given URI.
+func (s *CloudSchedulerSourceStatus) MarkDeadLetterSink(uri string) {
+	s.DeadLetterSinkURI = uri
+	cloudSchedulerSourceCondSet.Manage(s).MarkTrue(CloudSchedulerSourceConditionDeadLetterSinkProvided)
+}
+
+// MarkNoDeadLetterSink sets the condition that the source's dead letter sink
+// could not be resolved.
+func (s *CloudSchedulerSourceStatus) MarkNoDeadLetterSink(reason, messageFormat string, messageA ...interface{}) {
+	cloudSchedulerSourceCondSet.Manage(s).MarkFalse(CloudSchedulerSourceConditionDeadLetterSinkProvided, reason, messageFormat, messageA...)
+}
+
+// MarkServiceReady sets the condition that the Receive Adapter Service is ready.
+func (s *CloudSchedulerSourceStatus) MarkServiceReady() {
+	cloudSchedulerSourceCondSet.Manage(s).MarkTrue(CloudSchedulerSourceConditionServiceReady)
+}
+
+// MarkServiceNotReady sets the condition that the Receive Adapter Service is
+// not ready yet.
+func (s *CloudSchedulerSourceStatus) MarkServiceNotReady(reason, messageFormat string, messageA ...interface{}) {
+	cloudSchedulerSourceCondSet.Manage(s).MarkFalse(CloudSchedulerSourceConditionServiceReady, reason, messageFormat, messageA...)
+}
+
+// MarkJob sets the condition that the Cloud Scheduler Job has been
+// reconciled and records its name.
+func (s *CloudSchedulerSourceStatus) MarkJob(name string) {
+	s.Job = name
+	cloudSchedulerSourceCondSet.Manage(s).MarkTrue(CloudSchedulerSourceConditionJobReady)
+}
+
+// MarkJobFailed sets the condition that the Cloud Scheduler Job could not
+// be reconciled.
+func (s *CloudSchedulerSourceStatus) MarkJobFailed(reason, messageFormat string, messageA ...interface{}) {
+	cloudSchedulerSourceCondSet.Manage(s).MarkFalse(CloudSchedulerSourceConditionJobReady, reason, messageFormat, messageA...)
 }
 
 func (csr *CloudSchedulerSource) GetGroupVersionKind() schema.GroupVersionKind {
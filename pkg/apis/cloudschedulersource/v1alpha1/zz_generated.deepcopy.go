@@ -21,17 +21,58 @@ limitations under the License.
 package v1alpha1
 
 import (
+	duck_v1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
 	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppEngineTarget) DeepCopyInto(out *AppEngineTarget) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppEngineTarget.
+func (in *AppEngineTarget) DeepCopy() *AppEngineTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(AppEngineTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudEventsFormat) DeepCopyInto(out *CloudEventsFormat) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudEventsFormat.
+func (in *CloudEventsFormat) DeepCopy() *CloudEventsFormat {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudEventsFormat)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudSchedulerSource) DeepCopyInto(out *CloudSchedulerSource) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -89,6 +130,81 @@ func (in *CloudSchedulerSourceList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudSchedulerSourceSpec) DeepCopyInto(out *CloudSchedulerSourceSpec) {
 	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RetryConfig != nil {
+		in, out := &in.RetryConfig, &out.RetryConfig
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(RetryConfig)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.CloudEvents != nil {
+		in, out := &in.CloudEvents, &out.CloudEvents
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(CloudEventsFormat)
+			**out = **in
+		}
+	}
+	if in.SinkRetry != nil {
+		in, out := &in.SinkRetry, &out.SinkRetry
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SinkRetry)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Deduplication != nil {
+		in, out := &in.Deduplication, &out.Deduplication
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(Deduplication)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.PubSubTarget != nil {
+		in, out := &in.PubSubTarget, &out.PubSubTarget
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(PubSubTarget)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.AppEngineTarget != nil {
+		in, out := &in.AppEngineTarget, &out.AppEngineTarget
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(AppEngineTarget)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.OIDCToken != nil {
+		in, out := &in.OIDCToken, &out.OIDCToken
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(OIDCToken)
+			**out = **in
+		}
+	}
 	if in.Sink != nil {
 		in, out := &in.Sink, &out.Sink
 		if *in == nil {
@@ -98,6 +214,15 @@ func (in *CloudSchedulerSourceSpec) DeepCopyInto(out *CloudSchedulerSourceSpec)
 			**out = **in
 		}
 	}
+	if in.DeadLetterSink != nil {
+		in, out := &in.DeadLetterSink, &out.DeadLetterSink
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(v1.ObjectReference)
+			**out = **in
+		}
+	}
 	return
 }
 
@@ -114,6 +239,13 @@ func (in *CloudSchedulerSourceSpec) DeepCopy() *CloudSchedulerSourceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudSchedulerSourceStatus) DeepCopyInto(out *CloudSchedulerSourceStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(duck_v1alpha1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -126,3 +258,167 @@ func (in *CloudSchedulerSourceStatus) DeepCopy() *CloudSchedulerSourceStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Deduplication) DeepCopyInto(out *Deduplication) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.Duration)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Deduplication.
+func (in *Deduplication) DeepCopy() *Deduplication {
+	if in == nil {
+		return nil
+	}
+	out := new(Deduplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCToken) DeepCopyInto(out *OIDCToken) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCToken.
+func (in *OIDCToken) DeepCopy() *OIDCToken {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PubSubTarget) DeepCopyInto(out *PubSubTarget) {
+	*out = *in
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PubSubTarget.
+func (in *PubSubTarget) DeepCopy() *PubSubTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PubSubTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryConfig) DeepCopyInto(out *RetryConfig) {
+	*out = *in
+	if in.MaxRetryDuration != nil {
+		in, out := &in.MaxRetryDuration, &out.MaxRetryDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.Duration)
+			**out = **in
+		}
+	}
+	if in.MinBackoffDuration != nil {
+		in, out := &in.MinBackoffDuration, &out.MinBackoffDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.Duration)
+			**out = **in
+		}
+	}
+	if in.MaxBackoffDuration != nil {
+		in, out := &in.MaxBackoffDuration, &out.MaxBackoffDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.Duration)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryConfig.
+func (in *RetryConfig) DeepCopy() *RetryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SinkRetry) DeepCopyInto(out *SinkRetry) {
+	*out = *in
+	if in.MinBackoffDuration != nil {
+		in, out := &in.MinBackoffDuration, &out.MinBackoffDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.Duration)
+			**out = **in
+		}
+	}
+	if in.MaxBackoffDuration != nil {
+		in, out := &in.MaxBackoffDuration, &out.MaxBackoffDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.Duration)
+			**out = **in
+		}
+	}
+	if in.JitterPercent != nil {
+		in, out := &in.JitterPercent, &out.JitterPercent
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int32)
+			**out = **in
+		}
+	}
+	if in.RetryableStatusCodes != nil {
+		in, out := &in.RetryableStatusCodes, &out.RetryableStatusCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SinkRetry.
+func (in *SinkRetry) DeepCopy() *SinkRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(SinkRetry)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backend contains the interface the CloudSchedulerSource
+// reconciler uses to manage scheduled jobs, along with its implementations.
+package backend
+
+import (
+	"context"
+
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+)
+
+// JobBackend manages the lifecycle of the scheduled jobs backing
+// CloudSchedulerSources. Jobs are described using the Cloud Scheduler protos
+// regardless of the implementation, and a job that does not exist is reported
+// with a gRPC NotFound status error.
+type JobBackend interface {
+	// GetJob returns the job with the given name.
+	GetJob(ctx context.Context, req *schedulerpb.GetJobRequest) (*schedulerpb.Job, error)
+	// CreateJob creates a new job under the given parent.
+	CreateJob(ctx context.Context, req *schedulerpb.CreateJobRequest) (*schedulerpb.Job, error)
+	// UpdateJob updates an existing job.
+	UpdateJob(ctx context.Context, req *schedulerpb.UpdateJobRequest) (*schedulerpb.Job, error)
+	// DeleteJob deletes the job with the given name.
+	DeleteJob(ctx context.Context, req *schedulerpb.DeleteJobRequest) error
+	// PauseJob stops the job from being executed until it is resumed.
+	PauseJob(ctx context.Context, req *schedulerpb.PauseJobRequest) (*schedulerpb.Job, error)
+	// ResumeJob resumes a previously paused job.
+	ResumeJob(ctx context.Context, req *schedulerpb.ResumeJobRequest) (*schedulerpb.Job, error)
+	// RunJob forces the job to run immediately.
+	RunJob(ctx context.Context, req *schedulerpb.RunJobRequest) (*schedulerpb.Job, error)
+}
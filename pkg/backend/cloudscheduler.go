@@ -0,0 +1,72 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+
+	"cloud.google.com/go/scheduler/apiv1beta1"
+	"google.golang.org/api/option"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+)
+
+// cloudScheduler is a JobBackend that manages jobs in Google Cloud Scheduler.
+type cloudScheduler struct {
+	client *scheduler.CloudSchedulerClient
+}
+
+// Check that we implement the JobBackend interface.
+var _ JobBackend = (*cloudScheduler)(nil)
+
+// NewCloudScheduler returns a JobBackend backed by Google Cloud Scheduler.
+// The given options are passed through to the underlying client, for
+// example to use specific credentials or a different endpoint.
+func NewCloudScheduler(ctx context.Context, opts ...option.ClientOption) (JobBackend, error) {
+	client, err := scheduler.NewCloudSchedulerClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudScheduler{client: client}, nil
+}
+
+func (cs *cloudScheduler) GetJob(ctx context.Context, req *schedulerpb.GetJobRequest) (*schedulerpb.Job, error) {
+	return cs.client.GetJob(ctx, req)
+}
+
+func (cs *cloudScheduler) CreateJob(ctx context.Context, req *schedulerpb.CreateJobRequest) (*schedulerpb.Job, error) {
+	return cs.client.CreateJob(ctx, req)
+}
+
+func (cs *cloudScheduler) UpdateJob(ctx context.Context, req *schedulerpb.UpdateJobRequest) (*schedulerpb.Job, error) {
+	return cs.client.UpdateJob(ctx, req)
+}
+
+func (cs *cloudScheduler) DeleteJob(ctx context.Context, req *schedulerpb.DeleteJobRequest) error {
+	return cs.client.DeleteJob(ctx, req)
+}
+
+func (cs *cloudScheduler) PauseJob(ctx context.Context, req *schedulerpb.PauseJobRequest) (*schedulerpb.Job, error) {
+	return cs.client.PauseJob(ctx, req)
+}
+
+func (cs *cloudScheduler) ResumeJob(ctx context.Context, req *schedulerpb.ResumeJobRequest) (*schedulerpb.Job, error) {
+	return cs.client.ResumeJob(ctx, req)
+}
+
+func (cs *cloudScheduler) RunJob(ctx context.Context, req *schedulerpb.RunJobRequest) (*schedulerpb.Job, error) {
+	return cs.client.RunJob(ctx, req)
+}
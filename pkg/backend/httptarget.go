@@ -0,0 +1,64 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+)
+
+// CallHTTPTarget calls the HTTP target of the named job for the run
+// scheduled at the given time, with the headers Cloud Scheduler sets. The
+// error is a gRPC status, as recorded in the Status of the job.
+func CallHTTPTarget(ctx context.Context, client *http.Client, jobName string, scheduleTime time.Time, target *schedulerpb.HttpTarget) error {
+	method := target.HttpMethod.String()
+	if target.HttpMethod == schedulerpb.HttpMethod_HTTP_METHOD_UNSPECIFIED {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, target.Uri, bytes.NewReader(target.Body))
+	if err != nil {
+		return gstatus.Errorf(codes.InvalidArgument, "invalid HTTP target: %s", err)
+	}
+	req = req.WithContext(ctx)
+	for k, v := range target.Headers {
+		req.Header.Set(k, v)
+	}
+	// Mimic the headers set by Cloud Scheduler.
+	req.Header.Set("X-CloudScheduler", "true")
+	req.Header.Set("X-CloudScheduler-JobName", jobName[strings.LastIndex(jobName, "/")+1:])
+	req.Header.Set("X-CloudScheduler-ScheduleTime", scheduleTime.UTC().Format(time.RFC3339))
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return gstatus.Errorf(codes.Unavailable, "failed to call target: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return gstatus.Errorf(codes.Unknown, "target returned %s", resp.Status)
+	}
+	return nil
+}
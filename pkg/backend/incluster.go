@@ -0,0 +1,333 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"go.uber.org/zap"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+
+	"github.com/vaikas-google/csr/pkg/cron"
+)
+
+// inCluster is a JobBackend that evaluates the schedules itself and invokes
+// the jobs' HTTP targets from within the controller process. Jobs are only
+// kept in memory; after a restart the reconciler recreates them from the
+// CloudSchedulerSources.
+type inCluster struct {
+	client *http.Client
+	logger *zap.SugaredLogger
+	// timer returns a channel that receives once the given duration is
+	// over, and a function that stops it.
+	timer func(time.Duration) (<-chan time.Time, func() bool)
+
+	mu   sync.Mutex
+	jobs map[string]*inClusterJob
+}
+
+// inClusterJob is a job along with the state needed to run it.
+type inClusterJob struct {
+	job      *schedulerpb.Job
+	schedule cron.Schedule
+	location *time.Location
+	// stop is closed to stop the goroutine running the job, nil while the
+	// job is not running.
+	stop chan struct{}
+}
+
+// Check that we implement the JobBackend interface.
+var _ JobBackend = (*inCluster)(nil)
+
+// NewInCluster returns a JobBackend that runs jobs from within the
+// controller, so no Google Cloud project is needed. The given client is used
+// to call the jobs' HTTP targets, http.DefaultClient is used if it is nil.
+func NewInCluster(logger *zap.SugaredLogger, client *http.Client) JobBackend {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &inCluster{
+		client: client,
+		logger: logger,
+		timer:  newTimer,
+		jobs:   make(map[string]*inClusterJob),
+	}
+}
+
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
+func (ic *inCluster) GetJob(ctx context.Context, req *schedulerpb.GetJobRequest) (*schedulerpb.Job, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	j, err := ic.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return cloneJob(j.job), nil
+}
+
+func (ic *inCluster) CreateJob(ctx context.Context, req *schedulerpb.CreateJobRequest) (*schedulerpb.Job, error) {
+	if req.Job == nil || !strings.HasPrefix(req.Job.Name, req.Parent+"/jobs/") {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "job must be named under parent %q", req.Parent)
+	}
+	j, err := newInClusterJob(req.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if _, ok := ic.jobs[j.job.Name]; ok {
+		return nil, gstatus.Errorf(codes.AlreadyExists, "job %q already exists", j.job.Name)
+	}
+	j.job.State = schedulerpb.Job_ENABLED
+	ic.jobs[j.job.Name] = j
+	ic.start(j)
+	return cloneJob(j.job), nil
+}
+
+func (ic *inCluster) UpdateJob(ctx context.Context, req *schedulerpb.UpdateJobRequest) (*schedulerpb.Job, error) {
+	if req.Job == nil {
+		return nil, gstatus.Error(codes.InvalidArgument, "job is required")
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	existing, err := ic.get(req.Job.Name)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := applyUpdateMask(existing.job, req)
+	if err != nil {
+		return nil, err
+	}
+	j, err := newInClusterJob(updated)
+	if err != nil {
+		return nil, err
+	}
+	ic.halt(existing)
+	j.job.State = existing.job.State
+	j.job.LastAttemptTime = existing.job.LastAttemptTime
+	j.job.Status = existing.job.Status
+	ic.jobs[j.job.Name] = j
+	if j.job.State == schedulerpb.Job_ENABLED {
+		ic.start(j)
+	}
+	return cloneJob(j.job), nil
+}
+
+func (ic *inCluster) DeleteJob(ctx context.Context, req *schedulerpb.DeleteJobRequest) error {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	j, err := ic.get(req.Name)
+	if err != nil {
+		return err
+	}
+	ic.halt(j)
+	delete(ic.jobs, req.Name)
+	return nil
+}
+
+func (ic *inCluster) PauseJob(ctx context.Context, req *schedulerpb.PauseJobRequest) (*schedulerpb.Job, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	j, err := ic.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if j.job.State != schedulerpb.Job_ENABLED {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "job %q is %s", req.Name, j.job.State)
+	}
+	ic.halt(j)
+	j.job.State = schedulerpb.Job_PAUSED
+	j.job.ScheduleTime = nil
+	return cloneJob(j.job), nil
+}
+
+func (ic *inCluster) ResumeJob(ctx context.Context, req *schedulerpb.ResumeJobRequest) (*schedulerpb.Job, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	j, err := ic.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if j.job.State != schedulerpb.Job_PAUSED {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "job %q is %s", req.Name, j.job.State)
+	}
+	j.job.State = schedulerpb.Job_ENABLED
+	ic.start(j)
+	return cloneJob(j.job), nil
+}
+
+func (ic *inCluster) RunJob(ctx context.Context, req *schedulerpb.RunJobRequest) (*schedulerpb.Job, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	j, err := ic.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	go ic.run(j.job.Name, time.Now())
+	return cloneJob(j.job), nil
+}
+
+// applyUpdateMask returns the existing job with the fields named by the
+// update mask of the request replaced. Without a mask the request's job
+// replaces the existing one.
+func applyUpdateMask(existing *schedulerpb.Job, req *schedulerpb.UpdateJobRequest) (*schedulerpb.Job, error) {
+	if req.UpdateMask == nil || len(req.UpdateMask.Paths) == 0 {
+		return req.Job, nil
+	}
+	job := cloneJob(existing)
+	for _, p := range req.UpdateMask.Paths {
+		switch p {
+		case "description":
+			job.Description = req.Job.Description
+		case "target", "http_target":
+			job.Target = cloneJob(req.Job).Target
+		case "schedule":
+			job.Schedule = req.Job.Schedule
+		case "time_zone":
+			job.TimeZone = req.Job.TimeZone
+		case "retry_config":
+			job.RetryConfig = cloneJob(req.Job).RetryConfig
+		default:
+			return nil, gstatus.Errorf(codes.InvalidArgument, "unsupported update mask path %q", p)
+		}
+	}
+	return job, nil
+}
+
+func newInClusterJob(job *schedulerpb.Job) (*inClusterJob, error) {
+	if job.GetHttpTarget() == nil {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "job %q must have an HTTP target", job.Name)
+	}
+	schedule, err := cron.Parse(job.Schedule)
+	if err != nil {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "invalid schedule: %s", err)
+	}
+	loc := time.UTC
+	if job.TimeZone != "" {
+		if loc, err = time.LoadLocation(job.TimeZone); err != nil {
+			return nil, gstatus.Errorf(codes.InvalidArgument, "invalid time zone: %s", err)
+		}
+	}
+	job = cloneJob(job)
+	// Output only fields are set by the backend.
+	job.State = schedulerpb.Job_STATE_UNSPECIFIED
+	job.Status = nil
+	job.ScheduleTime = nil
+	job.LastAttemptTime = nil
+	job.UserUpdateTime = ptypes.TimestampNow()
+	return &inClusterJob{
+		job:      job,
+		schedule: schedule,
+		location: loc,
+	}, nil
+}
+
+// get returns the job with the given name. Callers must hold ic.mu.
+func (ic *inCluster) get(name string) (*inClusterJob, error) {
+	j, ok := ic.jobs[name]
+	if !ok {
+		return nil, gstatus.Errorf(codes.NotFound, "job %q not found", name)
+	}
+	return j, nil
+}
+
+// start starts running the job on its schedule. Callers must hold ic.mu.
+func (ic *inCluster) start(j *inClusterJob) {
+	j.stop = make(chan struct{})
+	go ic.loop(j, j.stop)
+}
+
+// halt stops running the job. Callers must hold ic.mu.
+func (ic *inCluster) halt(j *inClusterJob) {
+	if j.stop != nil {
+		close(j.stop)
+		j.stop = nil
+	}
+}
+
+// loop runs the job every time its schedule fires until stop is closed.
+func (ic *inCluster) loop(j *inClusterJob, stop chan struct{}) {
+	for {
+		next := j.schedule.Next(time.Now().In(j.location))
+		if next.IsZero() {
+			ic.logger.Infof("Job %q will never run again", j.job.Name)
+			return
+		}
+		ic.mu.Lock()
+		j.job.ScheduleTime, _ = ptypes.TimestampProto(next)
+		ic.mu.Unlock()
+
+		fired, stopTimer := ic.timer(time.Until(next))
+		select {
+		case <-stop:
+			stopTimer()
+			return
+		case <-fired:
+			// The job may have been stopped just as the timer fired.
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			go ic.run(j.job.Name, next)
+		}
+	}
+}
+
+// run invokes the named job's HTTP target once, for the given schedule time,
+// and records the outcome on the job.
+func (ic *inCluster) run(name string, scheduleTime time.Time) {
+	ic.mu.Lock()
+	j, err := ic.get(name)
+	if err != nil {
+		ic.mu.Unlock()
+		return
+	}
+	target := proto.Clone(j.job.GetHttpTarget()).(*schedulerpb.HttpTarget)
+	ic.mu.Unlock()
+
+	err = CallHTTPTarget(context.Background(), ic.client, name, scheduleTime, target)
+	if err != nil {
+		ic.logger.Infof("Failed to run job %q: %s", name, err)
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	// The job may have been updated or deleted while it was running.
+	if j, ok := ic.jobs[name]; ok {
+		j.job.LastAttemptTime = ptypes.TimestampNow()
+		j.job.Status = gstatus.Convert(err).Proto()
+	}
+}
+
+func cloneJob(job *schedulerpb.Job) *schedulerpb.Job {
+	return proto.Clone(job).(*schedulerpb.Job)
+}
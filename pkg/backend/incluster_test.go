@@ -0,0 +1,154 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+)
+
+const (
+	testParent  = "projects/testproject/locations/us-central1"
+	testJobName = testParent + "/jobs/testjob"
+)
+
+func TestInCluster(t *testing.T) {
+	calls := make(chan http.Header, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- r.Header
+	}))
+	defer target.Close()
+
+	ic := NewInCluster(zap.NewNop().Sugar(), nil).(*inCluster)
+	// Timers only fire when the test says so.
+	timers := make(chan chan time.Time, 10)
+	ic.timer = func(time.Duration) (<-chan time.Time, func() bool) {
+		c := make(chan time.Time, 1)
+		timers <- c
+		return c, func() bool { return true }
+	}
+	nextTimer := func() chan time.Time {
+		t.Helper()
+		select {
+		case c := <-timers:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the job to be scheduled")
+			return nil
+		}
+	}
+	wantNoCall := func() {
+		t.Helper()
+		select {
+		case h := <-calls:
+			t.Errorf("Target got an unexpected call with headers %v", h)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+	job, err := ic.CreateJob(ctx, &schedulerpb.CreateJobRequest{
+		Parent: testParent,
+		Job: &schedulerpb.Job{
+			Name:     testJobName,
+			Schedule: "* * * * *",
+			Target: &schedulerpb.Job_HttpTarget{
+				HttpTarget: &schedulerpb.HttpTarget{Uri: target.URL},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateJob() = %v", err)
+	}
+	if job.State != schedulerpb.Job_ENABLED {
+		t.Errorf("State = %s, want %s", job.State, schedulerpb.Job_ENABLED)
+	}
+
+	// The job fires when its timer does.
+	timer := nextTimer()
+	if job, err = ic.GetJob(ctx, &schedulerpb.GetJobRequest{Name: testJobName}); err != nil || job.ScheduleTime == nil {
+		t.Errorf("GetJob() = (%v, %v), want a job with a schedule time", job, err)
+	}
+	timer <- time.Now()
+	select {
+	case h := <-calls:
+		scheduleTime, err := time.Parse(time.RFC3339, h.Get("X-CloudScheduler-ScheduleTime"))
+		if err != nil {
+			t.Fatalf("Invalid schedule time header: %v", err)
+		}
+		if !scheduleTime.After(start) || scheduleTime.Second() != 0 {
+			t.Errorf("Schedule time = %v, want the first minute after %v", scheduleTime, start)
+		}
+		if got, want := h.Get("X-CloudScheduler-JobName"), "testjob"; got != want {
+			t.Errorf("Header X-CloudScheduler-JobName = %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the target to be called")
+	}
+
+	// A paused job doesn't, not even if its timer already did.
+	timer = nextTimer()
+	if job, err = ic.PauseJob(ctx, &schedulerpb.PauseJobRequest{Name: testJobName}); err != nil || job.State != schedulerpb.Job_PAUSED {
+		t.Fatalf("PauseJob() = (%v, %v), want a PAUSED job", job, err)
+	}
+	timer <- time.Now()
+	wantNoCall()
+
+	// A deleted job doesn't either.
+	if job, err = ic.ResumeJob(ctx, &schedulerpb.ResumeJobRequest{Name: testJobName}); err != nil || job.State != schedulerpb.Job_ENABLED {
+		t.Fatalf("ResumeJob() = (%v, %v), want an ENABLED job", job, err)
+	}
+	timer = nextTimer()
+	if err := ic.DeleteJob(ctx, &schedulerpb.DeleteJobRequest{Name: testJobName}); err != nil {
+		t.Fatalf("DeleteJob() = %v", err)
+	}
+	timer <- time.Now()
+	wantNoCall()
+	if _, err := ic.GetJob(ctx, &schedulerpb.GetJobRequest{Name: testJobName}); gstatus.Code(err) != codes.NotFound {
+		t.Errorf("GetJob() of a deleted job = %v, want NotFound", err)
+	}
+	select {
+	case <-timers:
+		t.Error("Deleted job was scheduled again")
+	default:
+	}
+}
+
+func TestInClusterRejectsInvalidJobs(t *testing.T) {
+	ic := NewInCluster(zap.NewNop().Sugar(), nil)
+	target := &schedulerpb.Job_HttpTarget{HttpTarget: &schedulerpb.HttpTarget{Uri: "http://sink/"}}
+	for name, job := range map[string]*schedulerpb.Job{
+		"wrong parent":      {Name: "projects/other/locations/l/jobs/testjob", Schedule: "* * * * *", Target: target},
+		"invalid schedule":  {Name: testJobName, Schedule: "bad", Target: target},
+		"invalid time zone": {Name: testJobName, Schedule: "* * * * *", TimeZone: "Nowhere/Special", Target: target},
+		"no HTTP target":    {Name: testJobName, Schedule: "* * * * *"},
+	} {
+		_, err := ic.CreateJob(context.Background(), &schedulerpb.CreateJobRequest{Parent: testParent, Job: job})
+		if gstatus.Code(err) != codes.InvalidArgument {
+			t.Errorf("CreateJob() of a job with %s = %v, want InvalidArgument", name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+
+	"cloud.google.com/go/pubsub/apiv1"
+	"google.golang.org/api/option"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+)
+
+// PubSub manages the Pub/Sub topics that the jobs of CloudSchedulerSources
+// publish to, and the subscriptions their Receive Adapters pull from. Like
+// with JobBackend, missing topics and subscriptions are reported with a gRPC
+// NotFound status error, and existing ones with AlreadyExists.
+type PubSub interface {
+	// CreateTopic creates the given topic.
+	CreateTopic(ctx context.Context, req *pubsubpb.Topic) (*pubsubpb.Topic, error)
+	// DeleteTopic deletes the topic with the given name.
+	DeleteTopic(ctx context.Context, req *pubsubpb.DeleteTopicRequest) error
+	// CreateSubscription creates the given subscription.
+	CreateSubscription(ctx context.Context, req *pubsubpb.Subscription) (*pubsubpb.Subscription, error)
+	// DeleteSubscription deletes the subscription with the given name.
+	DeleteSubscription(ctx context.Context, req *pubsubpb.DeleteSubscriptionRequest) error
+}
+
+// cloudPubSub is a PubSub that manages topics and subscriptions in Google
+// Cloud Pub/Sub.
+type cloudPubSub struct {
+	publisher  *pubsub.PublisherClient
+	subscriber *pubsub.SubscriberClient
+}
+
+// Check that we implement the PubSub interface.
+var _ PubSub = (*cloudPubSub)(nil)
+
+// NewPubSub returns a PubSub backed by Google Cloud Pub/Sub. The given
+// options are passed through to the underlying clients.
+func NewPubSub(ctx context.Context, opts ...option.ClientOption) (PubSub, error) {
+	publisher, err := pubsub.NewPublisherClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	subscriber, err := pubsub.NewSubscriberClient(ctx, opts...)
+	if err != nil {
+		publisher.Close()
+		return nil, err
+	}
+	return &cloudPubSub{publisher: publisher, subscriber: subscriber}, nil
+}
+
+func (ps *cloudPubSub) CreateTopic(ctx context.Context, req *pubsubpb.Topic) (*pubsubpb.Topic, error) {
+	return ps.publisher.CreateTopic(ctx, req)
+}
+
+func (ps *cloudPubSub) DeleteTopic(ctx context.Context, req *pubsubpb.DeleteTopicRequest) error {
+	return ps.publisher.DeleteTopic(ctx, req)
+}
+
+func (ps *cloudPubSub) CreateSubscription(ctx context.Context, req *pubsubpb.Subscription) (*pubsubpb.Subscription, error) {
+	return ps.subscriber.CreateSubscription(ctx, req)
+}
+
+func (ps *cloudPubSub) DeleteSubscription(ctx context.Context, req *pubsubpb.DeleteSubscriptionRequest) error {
+	return ps.subscriber.DeleteSubscription(ctx, req)
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cron parses the schedules accepted by CloudSchedulerSources and
+// computes when they next fire.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes when a job runs.
+type Schedule interface {
+	// Next returns the first activation time strictly after t, in t's
+	// location. The zero time is returned if there is none.
+	Next(t time.Time) time.Time
+}
+
+// field describes the allowed values of one of the cron fields.
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = field{name: "minute", min: 0, max: 59}
+	hours   = field{name: "hour", min: 0, max: 23}
+	dom     = field{name: "day of month", min: 1, max: 31}
+	months  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 mean Sunday.
+	dow = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Expand returns the five field form of the @ macros, which Cloud Scheduler
+// doesn't accept, and any other schedule as is.
+func Expand(spec string) string {
+	if m, ok := macros[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		return m
+	}
+	return spec
+}
+
+// IsInterval returns true if the given schedule is an interval, such as
+// "every 5 minutes", rather than a unix-cron schedule.
+func IsInterval(spec string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(spec)), "every ")
+}
+
+// Parse parses a schedule. Both the standard five field unix-cron format
+// (minute, hour, day of month, month and day of week, including ranges, steps,
+// lists and names), the common @ macros such as "@daily", and simple
+// intervals such as "every 5 minutes" are accepted.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(Expand(spec))
+	if IsInterval(spec) {
+		return parseInterval(spec)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in schedule %q, found %d", spec, len(fields))
+	}
+	s := &cronSchedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], dom); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dow); err != nil {
+		return nil, err
+	}
+	// Fold Sunday as 7 onto 0.
+	if s.dow&(1<<7) != 0 {
+		s.dow = (s.dow &^ (1 << 7)) | 1
+	}
+	// Like in Vixie cron, a day field starting with "*", such as "*/2", is
+	// unrestricted as far as the other day field is concerned.
+	s.domStar = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[2], "?")
+	s.dowStar = strings.HasPrefix(fields[4], "*") || strings.HasPrefix(fields[4], "?")
+	return s, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into
+// a bit set of the allowed values.
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		b, err := parseRange(part, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+func parseRange(expr string, f field) (uint64, error) {
+	rangeAndStep := strings.SplitN(expr, "/", 2)
+	lowAndHigh := strings.SplitN(rangeAndStep[0], "-", 2)
+
+	var low, high int
+	var err error
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("invalid %s range %q", f.name, expr)
+		}
+		low, high = f.min, f.max
+	} else {
+		if low, err = parseValue(lowAndHigh[0], f); err != nil {
+			return 0, err
+		}
+		high = low
+		if len(lowAndHigh) > 1 {
+			if high, err = parseValue(lowAndHigh[1], f); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	step := 1
+	if len(rangeAndStep) > 1 {
+		if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid %s step %q", f.name, expr)
+		}
+		// "N/step" means from N to the end of the range.
+		if len(lowAndHigh) == 1 && lowAndHigh[0] != "*" && lowAndHigh[0] != "?" {
+			high = f.max
+		}
+	}
+	if low > high {
+		return 0, fmt.Errorf("invalid %s range %q: %d is after %d", f.name, expr, low, high)
+	}
+
+	var bits uint64
+	for i := low; i <= high; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %d out of range [%d, %d]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// cronSchedule is a Schedule described by the five unix-cron fields.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// When either of the day fields is unrestricted, only the other one
+	// applies, otherwise a day matching either of them is chosen.
+	domStar, dowStar bool
+}
+
+// Next implements Schedule.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	from := wall(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Give up if nothing matches within five years, for example for
+	// February 30th.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = date(t.Year(), t.Month()+1, 1, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = date(t.Year(), t.Month(), t.Day()+1, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 || !wall(t).After(from) {
+			// The latter happens when the clock is turned back at the
+			// end of daylight saving time: the repeated times ran already.
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// date is like time.Date, except that wall clock times skipped when the
+// clock is turned forward at the start of daylight saving time are moved
+// forward as well, instead of back.
+func date(year int, month time.Month, day, hour, min int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, min, 0, 0, loc)
+	if want := time.Date(year, month, day, hour, min, 0, 0, time.UTC); wall(t).Before(want) {
+		t = t.Add(want.Sub(wall(t)))
+	}
+	return t
+}
+
+// wall returns the wall clock time of t, to the minute, as a UTC time, which
+// compares times in the same location regardless of daylight saving time.
+func wall(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// intervalSchedule is a Schedule that fires at a fixed interval, counted
+// from midnight of every day in the location of the schedule. Intervals that
+// don't divide a day start over at midnight.
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func parseInterval(spec string) (Schedule, error) {
+	parts := strings.Fields(strings.ToLower(spec))
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid schedule %q, expected \"every N minutes\" or \"every N hours\"", spec)
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid interval %q in schedule %q", parts[1], spec)
+	}
+	var unit time.Duration
+	switch parts[2] {
+	case "min", "mins", "minute", "minutes":
+		unit = time.Minute
+	case "hour", "hours":
+		unit = time.Hour
+	default:
+		return nil, fmt.Errorf("invalid unit %q in schedule %q", parts[2], spec)
+	}
+	return &intervalSchedule{every: time.Duration(n) * unit}, nil
+}
+
+// Next implements Schedule.
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	y, m, d := t.Date()
+	// Count in wall clock time, so that the runs stay on the same times of
+	// the day across daylight saving time transitions.
+	elapsed := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+	for {
+		next := (elapsed/s.every + 1) * s.every
+		if next >= 24*time.Hour {
+			return date(y, m, d+1, 0, 0, t.Location())
+		}
+		// When the clock is turned back at the end of daylight saving
+		// time, the repeated times ran already.
+		if n := date(y, m, d, 0, int(next/time.Minute), t.Location()); n.After(t) {
+			return n
+		}
+		elapsed = next
+	}
+}
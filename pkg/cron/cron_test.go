@@ -0,0 +1,227 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation() = %v", err)
+	}
+	utc := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, time.UTC)
+	}
+	// A Saturday.
+	saturday := time.Date(2026, 10, 17, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		// want are the next few activation times, each computed from the
+		// previous one.
+		want []time.Time
+	}{{
+		name: "every minute",
+		spec: "* * * * *",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 17, 10, 8), utc(2026, 10, 17, 10, 9), utc(2026, 10, 17, 10, 10)},
+	}, {
+		name: "star step",
+		spec: "*/15 * * * *",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 17, 10, 15), utc(2026, 10, 17, 10, 30), utc(2026, 10, 17, 10, 45)},
+	}, {
+		name: "steps, ranges and names",
+		spec: "5/20 9-17 * * mon-fri",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 19, 9, 5), utc(2026, 10, 19, 9, 25), utc(2026, 10, 19, 9, 45)},
+	}, {
+		name: "lists",
+		spec: "0 9 * jan,jul sun",
+		from: saturday,
+		want: []time.Time{utc(2027, 1, 3, 9, 0), utc(2027, 1, 10, 9, 0), utc(2027, 1, 17, 9, 0)},
+	}, {
+		name: "yearly",
+		spec: "0 0 1 1 *",
+		from: saturday,
+		want: []time.Time{utc(2027, 1, 1, 0, 0), utc(2028, 1, 1, 0, 0), utc(2029, 1, 1, 0, 0)},
+	}, {
+		name: "macro",
+		spec: "@daily",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 18, 0, 0), utc(2026, 10, 19, 0, 0), utc(2026, 10, 20, 0, 0)},
+	}, {
+		name: "either day field",
+		spec: "0 12 20 * 5",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 20, 12, 0), utc(2026, 10, 23, 12, 0), utc(2026, 10, 30, 12, 0)},
+	}, {
+		name: "star step day of month and day of week",
+		spec: "0 0 */2 * mon",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 19, 0, 0), utc(2026, 11, 9, 0, 0), utc(2026, 11, 23, 0, 0)},
+	}, {
+		name: "sunday as 7",
+		spec: "0 0 * * 7",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 18, 0, 0), utc(2026, 10, 25, 0, 0), utc(2026, 11, 1, 0, 0)},
+	}, {
+		name: "never",
+		spec: "0 0 30 2 *",
+		from: saturday,
+		want: []time.Time{{}},
+	}, {
+		name: "every 2 hours",
+		spec: "every 2 hours",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 17, 12, 0), utc(2026, 10, 17, 14, 0), utc(2026, 10, 17, 16, 0)},
+	}, {
+		name: "every 7 mins",
+		spec: "every 7 mins",
+		from: saturday,
+		want: []time.Time{utc(2026, 10, 17, 10, 9), utc(2026, 10, 17, 10, 16), utc(2026, 10, 17, 10, 23)},
+	}, {
+		name: "interval starting over at midnight",
+		spec: "every 7 hours",
+		from: utc(2026, 10, 17, 20, 0),
+		want: []time.Time{utc(2026, 10, 17, 21, 0), utc(2026, 10, 18, 0, 0), utc(2026, 10, 18, 7, 0)},
+	}, {
+		name: "time zone",
+		spec: "0 9 * * *",
+		from: saturday.In(ny),
+		want: []time.Time{utc(2026, 10, 17, 13, 0), utc(2026, 10, 18, 13, 0), utc(2026, 10, 19, 13, 0)},
+	}, {
+		name: "interval in a time zone",
+		spec: "every 5 hours",
+		from: saturday.In(ny),
+		want: []time.Time{utc(2026, 10, 17, 14, 0), utc(2026, 10, 17, 19, 0), utc(2026, 10, 18, 0, 0)},
+	}, {
+		// 02:30 doesn't happen on March 8th.
+		name: "start of daylight saving time",
+		spec: "30 2 * * *",
+		from: time.Date(2026, 3, 7, 12, 0, 0, 0, ny),
+		want: []time.Time{time.Date(2026, 3, 9, 2, 30, 0, 0, ny), time.Date(2026, 3, 10, 2, 30, 0, 0, ny)},
+	}, {
+		// 01:30 happens twice on November 1st.
+		name: "end of daylight saving time",
+		spec: "30 1 * * *",
+		from: time.Date(2026, 10, 31, 12, 0, 0, 0, ny),
+		want: []time.Time{utc(2026, 11, 1, 5, 30), utc(2026, 11, 2, 6, 30)},
+	}, {
+		name: "interval at the start of daylight saving time",
+		spec: "every 2 hours",
+		from: time.Date(2026, 3, 8, 0, 30, 0, 0, ny),
+		want: []time.Time{time.Date(2026, 3, 8, 3, 0, 0, 0, ny), time.Date(2026, 3, 8, 4, 0, 0, 0, ny)},
+	}, {
+		name: "interval at the end of daylight saving time",
+		spec: "every 30 mins",
+		from: utc(2026, 11, 1, 5, 15).In(ny),
+		want: []time.Time{utc(2026, 11, 1, 5, 30), utc(2026, 11, 1, 7, 0), utc(2026, 11, 1, 7, 30)},
+	}, {
+		name: "interval in the repeated hour",
+		spec: "every 30 mins",
+		from: utc(2026, 11, 1, 6, 15).In(ny),
+		want: []time.Time{utc(2026, 11, 1, 7, 0)},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse(tc.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v", tc.spec, err)
+			}
+			from := tc.from
+			for _, want := range tc.want {
+				got := s.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%v) = %v, want %v", from, got, want)
+				}
+				if !got.IsZero() && got.Location() != from.Location() {
+					t.Errorf("Next(%v) = %v, want a time in %v", from, got, from.Location())
+				}
+				from = got
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"bad",
+		"* * * *",
+		"* * * * * *",
+		"61 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * foo *",
+		"1-2-3 * * * *",
+		"5-1 * * * *",
+		"*-5 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"@often",
+		"every 0 mins",
+		"every x hours",
+		"every 5 days",
+		"every 5",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) = nil, want an error", spec)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	for spec, want := range map[string]string{
+		"@yearly":       "0 0 1 1 *",
+		"@annually":     "0 0 1 1 *",
+		"@monthly":      "0 0 1 * *",
+		"@weekly":       "0 0 * * 0",
+		"@daily":        "0 0 * * *",
+		"@midnight":     "0 0 * * *",
+		" @HOURLY ":     "0 * * * *",
+		"*/5 * * * *":   "*/5 * * * *",
+		"every 5 mins":  "every 5 mins",
+		"@unknownmacro": "@unknownmacro",
+	} {
+		if got := Expand(spec); got != want {
+			t.Errorf("Expand(%q) = %q, want %q", spec, got, want)
+		}
+	}
+}
+
+func TestIsInterval(t *testing.T) {
+	for spec, want := range map[string]bool{
+		"every 5 mins":   true,
+		" Every 2 hours": true,
+		"*/5 * * * *":    false,
+		"@every 5m":      false,
+		"everyday":       false,
+	} {
+		if got := IsInterval(spec); got != want {
+			t.Errorf("IsInterval(%q) = %v, want %v", spec, got, want)
+		}
+	}
+}
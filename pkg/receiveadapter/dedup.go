@@ -0,0 +1,89 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+var (
+	// errDuplicate is returned for events that were already delivered.
+	errDuplicate = errors.New("event already delivered")
+
+	// errInFlight is returned for events that are being delivered, which
+	// may still fail.
+	errInFlight = errors.New("event being delivered")
+)
+
+// DedupCache remembers the IDs of the events recently delivered, so that
+// repeated deliveries of the same events can be dropped. The oldest IDs are
+// forgotten once it's full, and all of them once their TTL is over.
+type DedupCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu sync.Mutex
+	// ids holds when each event was delivered, or the zero time while it
+	// is being delivered.
+	ids *simplelru.LRU
+}
+
+// NewDedupCache returns a DedupCache of at most size IDs, remembered for
+// ttl, or until they're evicted if ttl is zero.
+func NewDedupCache(size int, ttl time.Duration) (*DedupCache, error) {
+	ids, err := simplelru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DedupCache{ttl: ttl, now: time.Now, ids: ids}, nil
+}
+
+// claim records that the event with the given ID is being delivered. It
+// returns errDuplicate if it was delivered already, and errInFlight if it's
+// being delivered, in which cases it shouldn't be delivered again.
+func (c *DedupCache) claim(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.ids.Get(id); ok {
+		delivered := v.(time.Time)
+		if delivered.IsZero() {
+			return errInFlight
+		}
+		if c.ttl == 0 || c.now().Sub(delivered) < c.ttl {
+			return errDuplicate
+		}
+	}
+	c.ids.Add(id, time.Time{})
+	return nil
+}
+
+// done records the outcome of the delivery of the event with the given ID.
+// Events that weren't delivered are forgotten, so that they can be tried
+// again.
+func (c *DedupCache) done(id string, delivered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if delivered {
+		c.ids.Add(id, c.now())
+	} else {
+		c.ids.Remove(id)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	c, err := NewDedupCache(2, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDedupCache() = %v", err)
+	}
+	now := time.Date(2018, 11, 20, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	if err := c.claim("a"); err != nil {
+		t.Fatalf("claim(a) = %v", err)
+	}
+	if err := c.claim("a"); err != errInFlight {
+		t.Errorf("claim(a) while in flight = %v, want %v", err, errInFlight)
+	}
+	c.done("a", true)
+	if err := c.claim("a"); err != errDuplicate {
+		t.Errorf("claim(a) once delivered = %v, want %v", err, errDuplicate)
+	}
+
+	// Failed deliveries can be tried again.
+	if err := c.claim("b"); err != nil {
+		t.Fatalf("claim(b) = %v", err)
+	}
+	c.done("b", false)
+	if err := c.claim("b"); err != nil {
+		t.Errorf("claim(b) after a failure = %v, want no error", err)
+	}
+	c.done("b", true)
+
+	// Expired IDs are forgotten.
+	now = now.Add(2 * time.Hour)
+	if err := c.claim("a"); err != nil {
+		t.Errorf("claim(a) after the TTL = %v, want no error", err)
+	}
+	c.done("a", true)
+
+	// So are the least recently used ones once the cache is full.
+	if err := c.claim("c"); err != nil {
+		t.Fatalf("claim(c) = %v", err)
+	}
+	c.done("c", true)
+	if err := c.claim("b"); err != nil {
+		t.Errorf("claim(b) after its eviction = %v, want no error", err)
+	}
+}
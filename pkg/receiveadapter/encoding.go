@@ -0,0 +1,141 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/knative/pkg/cloudevents"
+)
+
+const (
+	// The versions of the CloudEvents spec events can be sent with.
+	SpecVersion01 = "0.1"
+	SpecVersion02 = "0.2"
+	SpecVersion10 = "1.0"
+)
+
+// Format is how events are encoded in the requests to the Sink. The zero
+// value is CloudEvents 0.1 in binary mode, which the Receive Adapter has
+// always sent.
+type Format struct {
+	// SpecVersion is the version of the CloudEvents spec, one of the
+	// SpecVersion constants. 0.1 if empty.
+	SpecVersion string
+	// Structured puts the whole event in the body as JSON, instead of the
+	// attributes in headers.
+	Structured bool
+}
+
+// attributeNames are the names of the attributes of an event that were
+// renamed between the versions of the spec, keyed by version.
+var attributeNames = map[string]struct {
+	contentType string
+	schemaURL   string
+}{
+	SpecVersion02: {contentType: "contenttype", schemaURL: "schemaurl"},
+	SpecVersion10: {contentType: "datacontenttype", schemaURL: "dataschema"},
+}
+
+// Validate checks that events can be sent in the format.
+func (f Format) Validate() error {
+	switch f.SpecVersion {
+	case "", SpecVersion01, SpecVersion02, SpecVersion10:
+		return nil
+	default:
+		return fmt.Errorf("unsupported CloudEvents spec version %q", f.SpecVersion)
+	}
+}
+
+// newRequest returns a request sending an event with the given data and
+// context to the given sink in the format.
+func (f Format) newRequest(sink string, data interface{}, ec cloudevents.EventContext) (*http.Request, error) {
+	switch f.SpecVersion {
+	case "", SpecVersion01:
+		if f.Structured {
+			return cloudevents.Structured.NewRequest(sink, data, ec)
+		}
+		return cloudevents.Binary.NewRequest(sink, data, ec)
+	case SpecVersion02, SpecVersion10:
+	default:
+		return nil, fmt.Errorf("unsupported CloudEvents spec version %q", f.SpecVersion)
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink, nil)
+	if err != nil {
+		return nil, err
+	}
+	attrs := f.attributes(ec)
+
+	if f.Structured {
+		envelope := make(map[string]interface{}, len(attrs)+1)
+		for k, v := range attrs {
+			envelope[k] = v
+		}
+		envelope["data"] = json.RawMessage(b)
+		if b, err = json.Marshal(envelope); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", cloudevents.ContentTypeStructuredJSON)
+	} else {
+		contentType := attributeNames[f.SpecVersion].contentType
+		for k, v := range attrs {
+			if k == contentType {
+				req.Header.Set("Content-Type", fmt.Sprint(v))
+				continue
+			}
+			req.Header.Set("ce-"+k, fmt.Sprint(v))
+		}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.ContentLength = int64(len(b))
+	return req, nil
+}
+
+// attributes returns the attributes of an event with the given context, by
+// their names in the spec version of the format. Extensions are attributes
+// as well since 0.2.
+func (f Format) attributes(ec cloudevents.EventContext) map[string]interface{} {
+	names := attributeNames[f.SpecVersion]
+	attrs := make(map[string]interface{}, len(ec.Extensions)+7)
+	for k, v := range ec.Extensions {
+		attrs[k] = v
+	}
+	attrs["specversion"] = f.SpecVersion
+	attrs["id"] = ec.EventID
+	attrs["type"] = ec.EventType
+	attrs["source"] = ec.Source
+	if !ec.EventTime.IsZero() {
+		attrs["time"] = ec.EventTime.UTC().Format(time.RFC3339Nano)
+	}
+	if ec.ContentType != "" {
+		attrs[names.contentType] = ec.ContentType
+	}
+	if ec.SchemaURL != "" {
+		attrs[names.schemaURL] = ec.SchemaURL
+	}
+	return attrs
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/pkg/cloudevents"
+)
+
+func TestFormatNewRequest(t *testing.T) {
+	ec := cloudevents.EventContext{
+		CloudEventsVersion: cloudevents.CloudEventsVersion,
+		EventType:          EventType,
+		EventID:            "1234",
+		EventTime:          time.Date(2018, 11, 20, 12, 0, 0, 0, time.UTC),
+		ContentType:        "application/json",
+		Source:             EventSource,
+		Extensions:         map[string]interface{}{"xteam": "billing"},
+	}
+	tests := []struct {
+		name        string
+		format      Format
+		wantHeaders map[string]string
+		// wantBody is the JSON body, compared after decoding.
+		wantBody string
+	}{{
+		name: "0.1 binary",
+		wantHeaders: map[string]string{
+			"Content-Type":          "application/json",
+			"CE-CloudEventsVersion": "0.1",
+			"CE-EventID":            "1234",
+			"CE-EventType":          EventType,
+			"CE-Source":             EventSource,
+			"CE-X-xteam":            `"billing"`,
+		},
+		wantBody: `"hello"`,
+	}, {
+		name:   "0.1 structured",
+		format: Format{Structured: true},
+		wantHeaders: map[string]string{
+			"Content-Type": "application/cloudevents+json",
+		},
+		wantBody: `{
+			"cloudEventsVersion": "0.1",
+			"eventID": "1234",
+			"eventTime": "2018-11-20T12:00:00Z",
+			"eventType": "com.google.cloud.scheduler.job.execute",
+			"contentType": "application/json",
+			"source": "GCPCloudScheduler",
+			"extensions": {"xteam": "billing"},
+			"data": "hello"
+		}`,
+	}, {
+		name:   "0.2 binary",
+		format: Format{SpecVersion: SpecVersion02},
+		wantHeaders: map[string]string{
+			"Content-Type":   "application/json",
+			"ce-specversion": "0.2",
+			"ce-id":          "1234",
+			"ce-time":        "2018-11-20T12:00:00Z",
+			"ce-type":        EventType,
+			"ce-source":      EventSource,
+			"ce-xteam":       "billing",
+		},
+		wantBody: `"hello"`,
+	}, {
+		name:   "1.0 binary",
+		format: Format{SpecVersion: SpecVersion10},
+		wantHeaders: map[string]string{
+			"Content-Type":   "application/json",
+			"ce-specversion": "1.0",
+			"ce-id":          "1234",
+			"ce-type":        EventType,
+			"ce-source":      EventSource,
+			"ce-xteam":       "billing",
+		},
+		wantBody: `"hello"`,
+	}, {
+		name:   "1.0 structured",
+		format: Format{SpecVersion: SpecVersion10, Structured: true},
+		wantHeaders: map[string]string{
+			"Content-Type": "application/cloudevents+json",
+		},
+		wantBody: `{
+			"specversion": "1.0",
+			"id": "1234",
+			"time": "2018-11-20T12:00:00Z",
+			"type": "com.google.cloud.scheduler.job.execute",
+			"datacontenttype": "application/json",
+			"source": "GCPCloudScheduler",
+			"xteam": "billing",
+			"data": "hello"
+		}`,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := tc.format.newRequest("http://sink/", "hello", ec)
+			if err != nil {
+				t.Fatalf("newRequest() = %v", err)
+			}
+			// Some headers are set without being canonicalized, as they
+			// would be once received.
+			header := http.Header{}
+			for k, v := range req.Header {
+				header[http.CanonicalHeaderKey(k)] = v
+			}
+			for h, want := range tc.wantHeaders {
+				if got := header.Get(h); got != want {
+					t.Errorf("Header %s = %q, want %q", h, got, want)
+				}
+			}
+			b, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("Body %s isn't JSON: %v", b, err)
+			}
+			if err := json.Unmarshal([]byte(tc.wantBody), &want); err != nil {
+				t.Fatalf("Invalid wantBody: %v", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Body (-want +got): %s", diff)
+			}
+		})
+	}
+}
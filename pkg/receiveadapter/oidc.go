@@ -0,0 +1,314 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GoogleCertsURL is where Google publishes the keys it signs OIDC
+	// tokens with, as a JSON Web Key Set.
+	GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// defaultKeysMaxAge is how long keys are cached when the response
+	// doesn't say.
+	defaultKeysMaxAge = time.Hour
+
+	// minKeysRefresh limits how often unknown key IDs make the keys be
+	// fetched again.
+	minKeysRefresh = time.Minute
+
+	// minKeysRetry and maxKeysRetry bound how long to wait before fetching
+	// the keys again after failing to. The wait doubles with every failure.
+	minKeysRetry = 5 * time.Second
+	maxKeysRetry = 5 * time.Minute
+
+	// clockSkew is how far off the clocks of Google and the Receive Adapter
+	// may be when checking the times of a token.
+	clockSkew = time.Minute
+)
+
+// googleIssuers are the issuers of the OIDC tokens Google signs.
+var googleIssuers = map[string]bool{
+	"https://accounts.google.com": true,
+	"accounts.google.com":         true,
+}
+
+var maxAgeRegexp = regexp.MustCompile(`max-age=(\d+)`)
+
+// KeySource looks up the public keys OIDC tokens are signed with.
+type KeySource interface {
+	// Key returns the key with the given ID.
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// JWKSKeySource is a KeySource that fetches the keys from a JSON Web Key
+// Set, and caches them for as long as the response allows.
+type JWKSKeySource struct {
+	// URL of the key set, usually GoogleCertsURL.
+	URL string
+	// Client fetches the key set. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	expiry  time.Time
+	// retryAt is when the keys may be fetched again after failures to.
+	retryAt  time.Time
+	failures uint
+
+	// now returns the current time, if set. Used by the tests.
+	now func() time.Time
+}
+
+// Check that we implement the KeySource interface.
+var _ KeySource = (*JWKSKeySource)(nil)
+
+// Key implements KeySource. The keys are fetched again once they expire, or
+// when the ID is unknown, since Google rotates the keys regularly. When that
+// fails the expired keys keep being used, and fetching them is retried with
+// a backoff.
+func (s *JWKSKeySource) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	key, ok := s.keys[kid]
+	if ok && now.Before(s.expiry) {
+		return key, nil
+	}
+	if (ok || now.Sub(s.fetched) >= minKeysRefresh) && !now.Before(s.retryAt) {
+		if err := s.fetch(ctx, now); err != nil {
+			backoff := maxKeysRetry
+			if s.failures < 16 && minKeysRetry<<s.failures < maxKeysRetry {
+				backoff = minKeysRetry << s.failures
+			}
+			s.failures++
+			s.retryAt = now.Add(backoff)
+			if !ok {
+				return nil, err
+			}
+			log.Printf("Failed to refresh the OIDC keys, using the cached ones: %s", err)
+			return key, nil
+		}
+		s.failures = 0
+		s.retryAt = time.Time{}
+		key, ok = s.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// fetch replaces the cached keys with the ones currently in the key set.
+func (s *JWKSKeySource) fetch(ctx context.Context, now time.Time) error {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to fetch keys: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch keys: %s returned %s", s.URL, resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode keys: %s", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("invalid modulus of key %q: %s", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("invalid exponent of key %q: %s", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	maxAge := defaultKeysMaxAge
+	if m := maxAgeRegexp.FindStringSubmatch(resp.Header.Get("Cache-Control")); m != nil {
+		if secs, err := strconv.Atoi(m[1]); err == nil {
+			maxAge = time.Duration(secs) * time.Second
+		}
+	}
+	s.keys = keys
+	s.fetched = now
+	s.expiry = now.Add(maxAge)
+	return nil
+}
+
+// TokenVerifier verifies the OIDC tokens Cloud Scheduler calls the Receive
+// Adapter with.
+type TokenVerifier struct {
+	// Keys looks up the keys the tokens are signed with.
+	Keys KeySource
+	// Email of the service account the tokens must be issued for.
+	Email string
+	// Audience the tokens must be issued for. If empty, the URL of the
+	// request, which Cloud Scheduler uses by default.
+	Audience string
+
+	// now returns the current time, if set. Used by the tests.
+	now func() time.Time
+}
+
+// claims are the claims of a Google OIDC token that are verified.
+type claims struct {
+	Issuer        string `json:"iss"`
+	Audience      string `json:"aud"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Expiry        int64  `json:"exp"`
+	IssuedAt      int64  `json:"iat"`
+}
+
+// VerifyRequest checks that the given request carries a bearer token signed
+// by Google for the expected service account and audience.
+func (v *TokenVerifier) VerifyRequest(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return fmt.Errorf("missing bearer token")
+	}
+	audiences := []string{v.Audience}
+	if v.Audience == "" {
+		// The scheme is lost behind the ingress, so accept both.
+		audiences = []string{
+			"http://" + r.Host + r.URL.Path,
+			"https://" + r.Host + r.URL.Path,
+		}
+	}
+	return v.Verify(r.Context(), strings.TrimSpace(auth[len("Bearer "):]), audiences...)
+}
+
+// Verify checks the signature of the given token, and that it was issued by
+// Google for the expected service account and one of the given audiences.
+func (v *TokenVerifier) Verify(ctx context.Context, token string, audiences ...string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("malformed token header: %s", err)
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("unexpected signing algorithm %q", header.Alg)
+	}
+	key, err := v.Keys.Key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed token signature: %s", err)
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig); err != nil {
+		return fmt.Errorf("invalid token signature: %s", err)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return fmt.Errorf("malformed token claims: %s", err)
+	}
+	if !googleIssuers[c.Issuer] {
+		return fmt.Errorf("unexpected issuer %q", c.Issuer)
+	}
+	if !containsString(audiences, c.Audience) {
+		return fmt.Errorf("unexpected audience %q", c.Audience)
+	}
+	if c.Email != v.Email || !c.EmailVerified {
+		return fmt.Errorf("unexpected email %q", c.Email)
+	}
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+	if now.Add(-clockSkew).After(time.Unix(c.Expiry, 0)) {
+		return fmt.Errorf("token expired")
+	}
+	if now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return fmt.Errorf("token issued in the future")
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyID    = "test-key"
+	testEmail    = "scheduler@project.iam.gserviceaccount.com"
+	testAudience = "http://testsource.testnamespace.example.com/"
+)
+
+var testNow = time.Date(2018, 11, 20, 12, 0, 0, 0, time.UTC)
+
+// staticKeys is a KeySource with fixed keys.
+type staticKeys map[string]*rsa.PublicKey
+
+func (k staticKeys) Key(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// signToken returns a token with the given header and claims, signed with
+// the given key.
+func signToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	t.Helper()
+	var parts []string
+	for _, seg := range []map[string]interface{}{header, claims} {
+		b, err := json.Marshal(seg)
+		if err != nil {
+			t.Fatalf("Failed to marshal token: %v", err)
+		}
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(b))
+	}
+	hash := sha256.Sum256([]byte(strings.Join(parts, ".")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return strings.Join(append(parts, base64.RawURLEncoding.EncodeToString(sig)), ".")
+}
+
+func TestVerifyRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		audience string
+		key      *rsa.PrivateKey
+		header   func(map[string]interface{})
+		claims   func(map[string]interface{})
+		auth     func(token string) string
+		wantErr  string
+	}{{
+		name: "valid",
+	}, {
+		name:     "valid with configured audience",
+		audience: "https://events.example.com",
+		claims:   func(c map[string]interface{}) { c["aud"] = "https://events.example.com" },
+	}, {
+		name:   "valid with https audience",
+		claims: func(c map[string]interface{}) { c["aud"] = "https://testsource.testnamespace.example.com/" },
+	}, {
+		name:    "missing token",
+		auth:    func(string) string { return "" },
+		wantErr: "missing bearer token",
+	}, {
+		name:    "not a bearer token",
+		auth:    func(token string) string { return "Basic " + token },
+		wantErr: "missing bearer token",
+	}, {
+		name:    "signed with another key",
+		key:     otherKey,
+		wantErr: "invalid token signature",
+	}, {
+		name:    "unknown key",
+		header:  func(h map[string]interface{}) { h["kid"] = "other-key" },
+		wantErr: `unknown key "other-key"`,
+	}, {
+		name:    "unsigned",
+		header:  func(h map[string]interface{}) { h["alg"] = "none" },
+		wantErr: `unexpected signing algorithm "none"`,
+	}, {
+		name:    "wrong issuer",
+		claims:  func(c map[string]interface{}) { c["iss"] = "https://example.com" },
+		wantErr: `unexpected issuer "https://example.com"`,
+	}, {
+		name:    "wrong audience",
+		claims:  func(c map[string]interface{}) { c["aud"] = "http://other.example.com/" },
+		wantErr: `unexpected audience "http://other.example.com/"`,
+	}, {
+		name:     "request URL with configured audience",
+		audience: "https://events.example.com",
+		wantErr:  "unexpected audience",
+	}, {
+		name:    "wrong email",
+		claims:  func(c map[string]interface{}) { c["email"] = "other@project.iam.gserviceaccount.com" },
+		wantErr: `unexpected email "other@project.iam.gserviceaccount.com"`,
+	}, {
+		name:    "unverified email",
+		claims:  func(c map[string]interface{}) { c["email_verified"] = false },
+		wantErr: "unexpected email",
+	}, {
+		name:    "expired",
+		claims:  func(c map[string]interface{}) { c["exp"] = testNow.Add(-time.Hour).Unix() },
+		wantErr: "token expired",
+	}, {
+		name:    "issued in the future",
+		claims:  func(c map[string]interface{}) { c["iat"] = testNow.Add(time.Hour).Unix() },
+		wantErr: "token issued in the future",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			header := map[string]interface{}{"alg": "RS256", "kid": testKeyID, "typ": "JWT"}
+			if tc.header != nil {
+				tc.header(header)
+			}
+			claims := map[string]interface{}{
+				"iss":            "https://accounts.google.com",
+				"aud":            testAudience,
+				"email":          testEmail,
+				"email_verified": true,
+				"iat":            testNow.Add(-time.Minute).Unix(),
+				"exp":            testNow.Add(time.Hour).Unix(),
+			}
+			if tc.claims != nil {
+				tc.claims(claims)
+			}
+			signer := key
+			if tc.key != nil {
+				signer = tc.key
+			}
+			token := signToken(t, signer, header, claims)
+			auth := "Bearer " + token
+			if tc.auth != nil {
+				auth = tc.auth(token)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, testAudience, strings.NewReader("hello"))
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+			v := &TokenVerifier{
+				Keys:     staticKeys{testKeyID: &key.PublicKey},
+				Email:    testEmail,
+				Audience: tc.audience,
+				now:      func() time.Time { return testNow },
+			}
+			err := v.VerifyRequest(r)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyRequest() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("VerifyRequest() = %v, want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWKSKeySourceCachesKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Cache-Control", "public, max-age=3600, must-revalidate")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": testKeyID,
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	keys := &JWKSKeySource{URL: srv.URL}
+	for i := 0; i < 3; i++ {
+		got, err := keys.Key(context.Background(), testKeyID)
+		if err != nil {
+			t.Fatalf("Key() = %v", err)
+		}
+		if got.N.Cmp(key.N) != 0 || got.E != key.E {
+			t.Fatalf("Key() returned a different key")
+		}
+	}
+	// Unknown keys don't cause another fetch right away.
+	if _, err := keys.Key(context.Background(), "other-key"); err == nil {
+		t.Errorf("Key() of an unknown key succeeded")
+	}
+	if fetches != 1 {
+		t.Errorf("Fetched the keys %d times, want 1", fetches)
+	}
+}
+
+func TestJWKSKeySourceKeepsKeysOnFailure(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	fetches, failing := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600, must-revalidate")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": testKeyID,
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	now := testNow
+	keys := &JWKSKeySource{URL: srv.URL, now: func() time.Time { return now }}
+	if _, err := keys.Key(context.Background(), testKeyID); err != nil {
+		t.Fatalf("Key() = %v", err)
+	}
+
+	failing = true
+	for _, step := range []struct {
+		after       time.Duration
+		wantFetches int
+	}{
+		// The keys expired, and can't be refreshed.
+		{after: 2 * time.Hour, wantFetches: 2},
+		// Not retried until the backoff is over.
+		{after: time.Second, wantFetches: 2},
+		{after: 5 * time.Second, wantFetches: 3},
+		// Which doubles.
+		{after: 5 * time.Second, wantFetches: 3},
+		{after: 5 * time.Second, wantFetches: 4},
+	} {
+		now = now.Add(step.after)
+		got, err := keys.Key(context.Background(), testKeyID)
+		if err != nil {
+			t.Fatalf("Key() after %v = %v, want the cached key", now.Sub(testNow), err)
+		}
+		if got.N.Cmp(key.N) != 0 {
+			t.Fatalf("Key() returned a different key")
+		}
+		if fetches != step.wantFetches {
+			t.Errorf("Fetched the keys %d times after %v, want %d", fetches, now.Sub(testNow), step.wantFetches)
+		}
+	}
+	// Unknown keys don't cause a fetch during the backoff either.
+	if _, err := keys.Key(context.Background(), "other-key"); err == nil {
+		t.Errorf("Key() of an unknown key succeeded")
+	}
+	if fetches != 4 {
+		t.Errorf("Fetched the keys %d times, want 4", fetches)
+	}
+
+	failing = false
+	now = now.Add(20 * time.Second)
+	if _, err := keys.Key(context.Background(), testKeyID); err != nil {
+		t.Fatalf("Key() = %v", err)
+	}
+	if fetches != 5 {
+		t.Errorf("Fetched the keys %d times, want 5", fetches)
+	}
+	// The refreshed keys are cached again.
+	now = now.Add(time.Minute)
+	if _, err := keys.Key(context.Background(), testKeyID); err != nil {
+		t.Fatalf("Key() = %v", err)
+	}
+	if fetches != 5 {
+		t.Errorf("Fetched the keys %d times, want 5", fetches)
+	}
+}
+
+func TestJWKSKeySourceFailsWithoutKeys(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	keys := &JWKSKeySource{URL: srv.URL}
+	if _, err := keys.Key(context.Background(), testKeyID); err == nil || !strings.Contains(err.Error(), "failed to fetch keys") {
+		t.Errorf("Key() = %v, want an error fetching the keys", err)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	gax "github.com/googleapis/gax-go"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+)
+
+const (
+	// pullBatchSize is the most messages pulled at once.
+	pullBatchSize = 10
+
+	// pullRetryDelay is how long to wait after a failed pull.
+	pullRetryDelay = 5 * time.Second
+
+	// defaultPullTimeout is how long a batch of pulled messages is tried to
+	// be sent for, unless PullTimeout says otherwise. It is below the 60
+	// seconds ack deadline of the subscriptions the controller creates.
+	defaultPullTimeout = 50 * time.Second
+)
+
+// Subscriber is the part of the Pub/Sub subscriber client used to pull
+// events, implemented by *pubsub.SubscriberClient.
+type Subscriber interface {
+	Pull(ctx context.Context, req *pubsubpb.PullRequest, opts ...gax.CallOption) (*pubsubpb.PullResponse, error)
+	Acknowledge(ctx context.Context, req *pubsubpb.AcknowledgeRequest, opts ...gax.CallOption) error
+	ModifyAckDeadline(ctx context.Context, req *pubsubpb.ModifyAckDeadlineRequest, opts ...gax.CallOption) error
+}
+
+// Pull sends the messages published to the given subscription, by the jobs
+// of sources with a Pub/Sub target, to the Sink until the context is done.
+// Messages are acknowledged once the Sink accepted them, the others are
+// redelivered by Pub/Sub.
+func (ra *CloudSchedulerReceiveAdapter) Pull(ctx context.Context, subscriber Subscriber, subscription string) error {
+	for {
+		resp, err := subscriber.Pull(ctx, &pubsubpb.PullRequest{
+			Subscription: subscription,
+			MaxMessages:  pullBatchSize,
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if code := gstatus.Code(err); code == codes.NotFound || code == codes.PermissionDenied {
+				return err
+			}
+			log.Printf("Failed to pull from %q: %s", subscription, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pullRetryDelay):
+			}
+			continue
+		}
+		ra.sendPulled(ctx, subscriber, subscription, resp.ReceivedMessages)
+	}
+}
+
+// sendPulled sends the given messages to the Sink and acknowledges the ones
+// it accepted. The others are made available for redelivery right away.
+// Sending gives up on the messages not delivered before the ack deadline
+// is near, since Pub/Sub redelivers them by then anyway.
+func (ra *CloudSchedulerReceiveAdapter) sendPulled(ctx context.Context, subscriber Subscriber, subscription string, msgs []*pubsubpb.ReceivedMessage) {
+	timeout := ra.PullTimeout
+	if timeout <= 0 {
+		timeout = defaultPullTimeout
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var acks, nacks []string
+	for _, m := range msgs {
+		if m.Message == nil {
+			continue
+		}
+		log.Printf("Cloud Scheduler Receive Adapter pulled a message: %+v", string(m.Message.Data))
+		// Like late calls, redelivered messages keep the time they were
+		// published at.
+		var publishTime time.Time
+		if t, err := ptypes.Timestamp(m.Message.PublishTime); err == nil {
+			publishTime = t
+		}
+		if err := ra.postMessage(sendCtx, string(m.Message.Data), m.Message.MessageId, publishTime, ra.pulledExtensions(m.Message)); err != nil {
+			log.Printf("Failed to send message %q: %s", m.Message.MessageId, err)
+			nacks = append(nacks, m.AckId)
+			continue
+		}
+		acks = append(acks, m.AckId)
+	}
+	if len(acks) > 0 {
+		err := subscriber.Acknowledge(ctx, &pubsubpb.AcknowledgeRequest{
+			Subscription: subscription,
+			AckIds:       acks,
+		})
+		if err != nil {
+			log.Printf("Failed to acknowledge %d messages: %s", len(acks), err)
+		}
+	}
+	if len(nacks) > 0 {
+		err := subscriber.ModifyAckDeadline(ctx, &pubsubpb.ModifyAckDeadlineRequest{
+			Subscription:       subscription,
+			AckIds:             nacks,
+			AckDeadlineSeconds: 0,
+		})
+		if err != nil {
+			log.Printf("Failed to release %d messages: %s", len(nacks), err)
+		}
+	}
+}
+
+// pulledExtensions returns the CloudEvent extensions for the given pulled
+// message: its attributes, named like forwarded headers, and the jobname and
+// publishtime of the message, the counterparts of the jobname and
+// scheduletime of calls.
+func (ra *CloudSchedulerReceiveAdapter) pulledExtensions(m *pubsubpb.PubsubMessage) map[string]interface{} {
+	var ext map[string]interface{}
+	add := func(name, v string) {
+		if name == "" || v == "" {
+			return
+		}
+		if ext == nil {
+			ext = make(map[string]interface{})
+		}
+		ext[name] = v
+	}
+	for k, v := range m.Attributes {
+		add(extensionName(k), v)
+	}
+	add("jobname", ra.JobName)
+	if t, err := ptypes.Timestamp(m.PublishTime); err == nil {
+		add("publishtime", t.UTC().Format(time.RFC3339Nano))
+	}
+	return ext
+}
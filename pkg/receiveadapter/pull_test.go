@@ -0,0 +1,142 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	gax "github.com/googleapis/gax-go"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+)
+
+// fakeSubscriber records the messages acknowledged and released.
+type fakeSubscriber struct {
+	acks, nacks []string
+}
+
+func (s *fakeSubscriber) Pull(ctx context.Context, req *pubsubpb.PullRequest, opts ...gax.CallOption) (*pubsubpb.PullResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *fakeSubscriber) Acknowledge(ctx context.Context, req *pubsubpb.AcknowledgeRequest, opts ...gax.CallOption) error {
+	s.acks = append(s.acks, req.AckIds...)
+	return nil
+}
+
+func (s *fakeSubscriber) ModifyAckDeadline(ctx context.Context, req *pubsubpb.ModifyAckDeadlineRequest, opts ...gax.CallOption) error {
+	s.nacks = append(s.nacks, req.AckIds...)
+	return nil
+}
+
+func TestSendPulled(t *testing.T) {
+	sink := newFakeSink(t)
+	defer sink.Close()
+	sink.statuses = []int{http.StatusOK, http.StatusBadRequest}
+
+	ra := &CloudSchedulerReceiveAdapter{
+		Sink:    sink.URL,
+		JobName: "testnamespace_testsource_test-uid",
+	}
+	subscriber := &fakeSubscriber{}
+	ra.sendPulled(context.Background(), subscriber, "sub", []*pubsubpb.ReceivedMessage{{
+		AckId: "accepted",
+		Message: &pubsubpb.PubsubMessage{
+			Data:        []byte("hello"),
+			MessageId:   "1234",
+			Attributes:  map[string]string{"team": "billing", "X-Region": "eu-west1", "-": "dropped"},
+			PublishTime: &timestamp.Timestamp{Seconds: 1542715200},
+		},
+	}, {
+		AckId:   "rejected",
+		Message: &pubsubpb.PubsubMessage{Data: []byte("hello"), MessageId: "5678"},
+	}})
+
+	if len(subscriber.acks) != 1 || subscriber.acks[0] != "accepted" {
+		t.Errorf("Acknowledged %v, want [accepted]", subscriber.acks)
+	}
+	if len(subscriber.nacks) != 1 || subscriber.nacks[0] != "rejected" {
+		t.Errorf("Released %v, want [rejected]", subscriber.nacks)
+	}
+	if len(sink.requests) != 2 {
+		t.Fatalf("Sink got %d events, want 2", len(sink.requests))
+	}
+	got := sink.requests[0].Header
+	for h, want := range map[string]string{
+		"CE-EventID":       "1234",
+		"CE-X-team":        `"billing"`,
+		"CE-X-xregion":     `"eu-west1"`,
+		"CE-X-jobname":     `"testnamespace_testsource_test-uid"`,
+		"CE-X-publishtime": `"2018-11-20T12:00:00Z"`,
+		// Redelivered messages keep the time they were published at.
+		"CE-EventTime": "2018-11-20T12:00:00Z",
+	} {
+		if v := got.Get(h); v != want {
+			t.Errorf("Header %s = %q, want %q", h, v, want)
+		}
+	}
+	if v := sink.requests[1].Header.Get("CE-X-publishtime"); v != "" {
+		t.Errorf("Header CE-X-publishtime = %q without a publish time, want none", v)
+	}
+}
+
+func TestSendPulledGivesUpOnHungSink(t *testing.T) {
+	release := make(chan struct{})
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer sink.Close()
+	defer close(release)
+
+	ra := &CloudSchedulerReceiveAdapter{
+		Sink:        sink.URL,
+		Retry:       &DefaultRetryPolicy,
+		PullTimeout: 100 * time.Millisecond,
+	}
+	subscriber := &fakeSubscriber{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ra.sendPulled(context.Background(), subscriber, "sub", []*pubsubpb.ReceivedMessage{{
+			AckId:   "first",
+			Message: &pubsubpb.PubsubMessage{Data: []byte("hello"), MessageId: "1234"},
+		}, {
+			AckId:   "second",
+			Message: &pubsubpb.PubsubMessage{Data: []byte("hello"), MessageId: "5678"},
+		}})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the messages to be given up on")
+	}
+
+	if len(subscriber.acks) != 0 {
+		t.Errorf("Acknowledged %v, want none", subscriber.acks)
+	}
+	if len(subscriber.nacks) != 2 {
+		t.Errorf("Released %v, want [first second]", subscriber.nacks)
+	}
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package receiveadapter
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,30 +31,142 @@ import (
 )
 
 const (
-	EventType   = "GoogleCloudScheduler"
+	// EventType is the type of the events of jobs.
+	EventType = "com.google.cloud.scheduler.job.execute"
+	// EventSource is the source of the events of adapters that aren't
+	// given the one of their job.
 	EventSource = "GCPCloudScheduler"
 )
 
+const (
+	// The headers Cloud Scheduler calls with, with the ID of the job and
+	// the time the call was scheduled for.
+	jobNameHeader      = "X-CloudScheduler-JobName"
+	scheduleTimeHeader = "X-CloudScheduler-ScheduleTime"
+)
+
 // CloudSchedulerReceiveAdapter converts incoming Cloud Scheduler events to
 // CloudEvents and then sends them to the specified Sink
 type CloudSchedulerReceiveAdapter struct {
 	Sink   string
 	Client *http.Client
+	// EventType and EventSource are the type and source attributes of the
+	// events. The EventType and EventSource constants if empty.
+	EventType   string
+	EventSource string
+	// Verifier checks the OIDC tokens of incoming requests, if set.
+	// Requests without a valid token are rejected.
+	Verifier *TokenVerifier
+	// ForwardHeaders are the names of the headers of incoming requests
+	// that are added to the events as extensions.
+	ForwardHeaders []string
+	// Retry controls how events the Sink didn't accept are retried. If
+	// nil, they aren't.
+	Retry *RetryPolicy
+	// Format is how events are encoded. CloudEvents 0.1 in binary mode if
+	// unset.
+	Format Format
+	// DeadLetterSink is where the events the Sink didn't accept are sent
+	// once retrying them is given up, if set.
+	DeadLetterSink string
+	// Dedup drops the events already delivered, if set, such as those of
+	// calls Cloud Scheduler retried after the Sink accepted them.
+	Dedup *DedupCache
+	// RequestTimeout limits how long the event of an incoming request is
+	// tried to be sent for, if set, so that the caller gets an answer
+	// before it gives up.
+	RequestTimeout time.Duration
+	// JobName is the ID of the job publishing the pulled messages, which
+	// unlike calls don't carry it.
+	JobName string
+	// PullTimeout limits how long a batch of pulled messages is tried to
+	// be sent for, so that the ones the Sink didn't accept yet are released
+	// before Pub/Sub redelivers them. 50 seconds if unset.
+	PullTimeout time.Duration
 }
 
 func (ra *CloudSchedulerReceiveAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ra.Verifier != nil {
+		if err := ra.Verifier.VerifyRequest(r); err != nil {
+			log.Printf("Rejecting unauthenticated request: %s", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	reqBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body of the request: %+v :: %+v", err, r)
+		http.Error(w, "failed to read the body", http.StatusBadRequest)
+		return
+	}
+	log.Printf("Cloud Scheduler Receive Adapter received a message: %+v", string(reqBytes))
+
+	// Only answer once the Sink did, so that Cloud Scheduler retries the
+	// events the Sink didn't accept.
+	ctx := r.Context()
+	if ra.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ra.RequestTimeout)
+		defer cancel()
+	}
+	if err := ra.postMessage(ctx, string(reqBytes), extractEventID(r), scheduleTime(r), ra.extensions(r)); err != nil {
+		log.Printf("Failed to send the event: %s", err)
+		http.Error(w, err.Error(), responseStatus(err))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
+}
+
+// sinkError is returned when the Sink doesn't accept an event.
+type sinkError struct {
+	// StatusCode and Status of the response of the Sink.
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the Sink asked to wait before retrying, if
+	// it did.
+	RetryAfter time.Duration
+}
 
-	if reqBytes, err := ioutil.ReadAll(r.Body); err == nil {
-		log.Printf("Cloud Scheduler Receive Adapter received a message: %+v", string(reqBytes))
-		ra.postMessage(string(reqBytes), extractEventID(r))
+func (e *sinkError) Error() string {
+	return fmt.Sprintf("sink returned %s", e.Status)
+}
 
-	} else {
-		log.Printf("Error reading body of the request: %+v :: %+v", err, r)
+// responseStatus returns the status to answer a request whose event
+// couldn't be sent with, which mirrors what happened at the Sink.
+func responseStatus(err error) int {
+	if se, ok := err.(*sinkError); ok {
+		if se.StatusCode >= 400 && se.StatusCode < 600 {
+			return se.StatusCode
+		}
+		return http.StatusBadGateway
+	}
+	if err == errInFlight {
+		// Ask to be called again once the delivery in progress is over.
+		return http.StatusConflict
 	}
+	if ue, ok := err.(*url.Error); ok {
+		// The Sink couldn't be reached.
+		if ue.Timeout() {
+			return http.StatusGatewayTimeout
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
 }
 
+// eventIDSpace is the namespace of the UUIDs of the events of the runs of
+// jobs.
+var eventIDSpace = uuid.NewSHA1(uuid.NameSpaceDNS, []byte("cloudscheduler.googleapis.com"))
+
+// extractEventID returns the ID of the event of the given request. All the
+// calls of a run of a job, including the retries, get the same ID, derived
+// from the name of the job and the time the run was scheduled for.
 func extractEventID(r *http.Request) string {
+	if job, t := r.Header.Get(jobNameHeader), scheduleTime(r); job != "" && !t.IsZero() {
+		name := job + "@" + t.UTC().Format(time.RFC3339Nano)
+		return uuid.NewSHA1(eventIDSpace, []byte(name)).String()
+	}
 	eventIDHeaders, ok := r.Header["X-Request-Id"]
 	if ok {
 		return string(eventIDHeaders[0])
@@ -61,37 +177,163 @@ func extractEventID(r *http.Request) string {
 	return ""
 }
 
-func (ra *CloudSchedulerReceiveAdapter) postMessage(payload string, eventID string) error {
-	ctx := cloudevents.EventContext{
+// scheduleTime returns the time the call of the given request was scheduled
+// for, which is zero if Cloud Scheduler didn't say.
+func scheduleTime(r *http.Request) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, r.Header.Get(scheduleTimeHeader))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// extensions returns the CloudEvent extensions for the given request: the
+// jobname and scheduletime of the call, and the forwarded headers.
+func (ra *CloudSchedulerReceiveAdapter) extensions(r *http.Request) map[string]interface{} {
+	var ext map[string]interface{}
+	add := func(name, v string) {
+		if v == "" {
+			return
+		}
+		if ext == nil {
+			ext = make(map[string]interface{})
+		}
+		ext[name] = v
+	}
+	add("jobname", r.Header.Get(jobNameHeader))
+	add("scheduletime", r.Header.Get(scheduleTimeHeader))
+	for _, name := range ra.ForwardHeaders {
+		add(extensionName(name), r.Header.Get(name))
+	}
+	return ext
+}
+
+// extensionName returns the name of the CloudEvent extension for the given
+// header, made of its lowercased letters and digits as CloudEvents requires.
+func extensionName(header string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, header)
+}
+
+// Send posts a single event with the given payload to the Sink, outside of
+// an incoming request. If eventID is empty a random one is used.
+func (ra *CloudSchedulerReceiveAdapter) Send(payload string, eventID string) error {
+	if eventID == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+		eventID = id.String()
+	}
+	return ra.postMessage(context.Background(), payload, eventID, time.Time{}, nil)
+}
+
+// postMessage sends an event with the given payload to the Sink, unless the
+// Dedup cache knows it was delivered already.
+func (ra *CloudSchedulerReceiveAdapter) postMessage(ctx context.Context, payload string, eventID string, eventTime time.Time, extensions map[string]interface{}) error {
+	if ra.Dedup == nil || eventID == "" {
+		return ra.deliver(ctx, payload, eventID, eventTime, extensions)
+	}
+	if err := ra.Dedup.claim(eventID); err == errDuplicate {
+		log.Printf("Dropping event %q, which was already delivered", eventID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err := ra.deliver(ctx, payload, eventID, eventTime, extensions)
+	ra.Dedup.done(eventID, err == nil)
+	return err
+}
+
+// deliver sends an event with the given payload to the Sink, retrying as
+// the Retry policy allows. Events that still couldn't be delivered are sent
+// to the DeadLetterSink, if there is one, which counts as delivered. The
+// event time is now if eventTime is zero.
+func (ra *CloudSchedulerReceiveAdapter) deliver(ctx context.Context, payload string, eventID string, eventTime time.Time, extensions map[string]interface{}) error {
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+	ec := cloudevents.EventContext{
 		CloudEventsVersion: cloudevents.CloudEventsVersion,
-		EventType:          EventType,
+		EventType:          ra.EventType,
 		EventID:            eventID,
-		EventTime:          time.Now(),
+		EventTime:          eventTime,
 		ContentType:        "application/json",
-		Source:             EventSource,
+		Source:             ra.EventSource,
+		Extensions:         extensions,
 	}
-	req, err := cloudevents.Binary.NewRequest(ra.Sink, payload, ctx)
+	if ec.EventType == "" {
+		ec.EventType = EventType
+	}
+	if ec.Source == "" {
+		ec.Source = EventSource
+	}
+	attempts, err := withRetries(ctx, ra.Retry, func() error {
+		return ra.send(ctx, ra.Sink, payload, ec)
+	})
+	if err == nil || ra.DeadLetterSink == "" {
+		return err
+	}
+	if dlErr := ra.sendDeadLetter(ctx, payload, ec, attempts, err); dlErr != nil {
+		log.Printf("Failed to send event %q to the dead letter sink: %s", eventID, dlErr)
+		return err
+	}
+	log.Printf("Sent event %q to the dead letter sink after %d attempts: %s", eventID, attempts, err)
+	return nil
+}
+
+// sendDeadLetter sends an event that couldn't be delivered to the Sink to
+// the DeadLetterSink, with extensions telling why.
+func (ra *CloudSchedulerReceiveAdapter) sendDeadLetter(ctx context.Context, payload string, ec cloudevents.EventContext, attempts int, deliveryErr error) error {
+	extensions := make(map[string]interface{}, len(ec.Extensions)+4)
+	for k, v := range ec.Extensions {
+		extensions[k] = v
+	}
+	extensions["deadlettersink"] = ra.Sink
+	extensions["deadletterreason"] = deliveryErr.Error()
+	extensions["deadletterattempts"] = attempts
+	if se, ok := deliveryErr.(*sinkError); ok {
+		extensions["deadletterstatus"] = se.StatusCode
+	}
+	ec.Extensions = extensions
+	return ra.send(ctx, ra.DeadLetterSink, payload, ec)
+}
+
+// send makes a single attempt at sending an event to the given sink.
+func (ra *CloudSchedulerReceiveAdapter) send(ctx context.Context, sink string, payload string, ec cloudevents.EventContext) error {
+	req, err := ra.Format.newRequest(sink, payload, ec)
 	if err != nil {
 		log.Printf("Failed to marshal the message: %+v : %s", payload, err)
 		return err
 	}
 
-	log.Printf("Posting payload %q to %q", payload, ra.Sink)
+	log.Printf("Posting payload %q to %q", payload, sink)
 	client := ra.Client
 	if client == nil {
 		client = &http.Client{}
 	}
-	resp, err := client.Do(req)
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// TODO: in general, receive adapters may have to be able to retry for error cases.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		log.Printf("response Status: %s", resp.Status)
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("response Body: %s", string(body))
+		return &sinkError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: retryAfter(resp),
+		}
 	}
 	return nil
 }
@@ -0,0 +1,328 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSink records the requests sent to it, and answers the n-th with the
+// n-th of statuses, or the last one if there are fewer. 200 if unset.
+type fakeSink struct {
+	*httptest.Server
+	statuses []int
+	header   http.Header
+	requests []*http.Request
+	bodies   []string
+}
+
+func newFakeSink(t *testing.T) *fakeSink {
+	s := &fakeSink{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read event: %v", err)
+		}
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		if n := len(s.statuses); n > 0 {
+			i := len(s.requests) - 1
+			if i >= n {
+				i = n - 1
+			}
+			w.WriteHeader(s.statuses[i])
+		}
+	}))
+	return s
+}
+
+func TestServeHTTPForwardsHeaders(t *testing.T) {
+	sink := newFakeSink(t)
+	defer sink.Close()
+
+	ra := &CloudSchedulerReceiveAdapter{
+		Sink:           sink.URL,
+		ForwardHeaders: []string{"X-Team", "X-Region", "X-Missing"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	r.Header.Set("X-Team", "billing")
+	r.Header.Set("X-Region", "eu-west1")
+	r.Header.Set("X-Other", "ignored")
+	w := httptest.NewRecorder()
+	ra.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("ServeHTTP() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if len(sink.requests) != 1 {
+		t.Fatalf("Sink got %d events, want 1", len(sink.requests))
+	}
+	got := sink.requests[0].Header
+	for h, want := range map[string]string{
+		"CE-X-xteam":    `"billing"`,
+		"CE-X-xregion":  `"eu-west1"`,
+		"CE-X-xother":   "",
+		"CE-X-xmissing": "",
+	} {
+		if v := got.Get(h); v != want {
+			t.Errorf("Header %s = %q, want %q", h, v, want)
+		}
+	}
+	// The body is sent as a JSON string.
+	if want := `"hello"`; sink.bodies[0] != want {
+		t.Errorf("Event body = %s, want %s", sink.bodies[0], want)
+	}
+}
+
+func TestServeHTTPAddsJobMetadata(t *testing.T) {
+	sink := newFakeSink(t)
+	defer sink.Close()
+
+	ra := &CloudSchedulerReceiveAdapter{Sink: sink.URL}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	r.Header.Set("X-CloudScheduler-JobName", "testnamespace_testsource_test-uid")
+	r.Header.Set("X-CloudScheduler-ScheduleTime", "2018-11-20T12:00:00Z")
+	w := httptest.NewRecorder()
+	ra.ServeHTTP(w, r)
+
+	if len(sink.requests) != 1 {
+		t.Fatalf("Sink got %d events, want 1", len(sink.requests))
+	}
+	got := sink.requests[0].Header
+	for h, want := range map[string]string{
+		"CE-X-jobname":      `"testnamespace_testsource_test-uid"`,
+		"CE-X-scheduletime": `"2018-11-20T12:00:00Z"`,
+		// Late or retried calls keep the time they were scheduled for.
+		"CE-EventTime": "2018-11-20T12:00:00Z",
+	} {
+		if v := got.Get(h); v != want {
+			t.Errorf("Header %s = %q, want %q", h, v, want)
+		}
+	}
+}
+
+func TestServeHTTPDropsDuplicates(t *testing.T) {
+	sink := newFakeSink(t)
+	defer sink.Close()
+	// The first call fails, so its retry is delivered, and the following
+	// ones are dropped.
+	sink.statuses = []int{http.StatusServiceUnavailable, http.StatusOK}
+
+	dedup, err := NewDedupCache(10, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDedupCache() = %v", err)
+	}
+	ra := &CloudSchedulerReceiveAdapter{Sink: sink.URL, Dedup: dedup}
+	call := func(scheduleTime string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+		r.Header.Set("X-CloudScheduler-JobName", "testnamespace_testsource_test-uid")
+		r.Header.Set("X-CloudScheduler-ScheduleTime", scheduleTime)
+		w := httptest.NewRecorder()
+		ra.ServeHTTP(w, r)
+		return w.Code
+	}
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+		if got := call("2018-11-20T12:00:00Z"); got != want {
+			t.Errorf("Call %d status = %d, want %d", i, got, want)
+		}
+	}
+	if len(sink.requests) != 2 {
+		t.Fatalf("Sink got %d events, want 2", len(sink.requests))
+	}
+	if first, retry := sink.requests[0].Header.Get("CE-EventID"), sink.requests[1].Header.Get("CE-EventID"); first != retry {
+		t.Errorf("Event ID of the retry = %q, want %q", retry, first)
+	}
+
+	// The next run is a different event.
+	call("2018-11-20T12:05:00Z")
+	if len(sink.requests) != 3 {
+		t.Fatalf("Sink got %d events, want 3", len(sink.requests))
+	}
+	if got, prev := sink.requests[2].Header.Get("CE-EventID"), sink.requests[1].Header.Get("CE-EventID"); got == prev {
+		t.Errorf("Event ID of the next run = %q, want a different one", got)
+	}
+}
+
+func TestServeHTTPMirrorsSinkStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		sinkStatus int
+		sinkDown   bool
+		wantStatus int
+	}{{
+		name:       "accepted",
+		sinkStatus: http.StatusAccepted,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "unavailable",
+		sinkStatus: http.StatusServiceUnavailable,
+		wantStatus: http.StatusServiceUnavailable,
+	}, {
+		name:       "rejected",
+		sinkStatus: http.StatusBadRequest,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "unreachable",
+		sinkDown:   true,
+		wantStatus: http.StatusBadGateway,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := newFakeSink(t)
+			defer sink.Close()
+			sink.statuses = []int{tc.sinkStatus}
+			if tc.sinkDown {
+				sink.Close()
+			}
+
+			ra := &CloudSchedulerReceiveAdapter{Sink: sink.URL}
+			w := httptest.NewRecorder()
+			ra.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+			if w.Code != tc.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServeHTTPRetries(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:          3,
+		MinBackoff:           time.Millisecond,
+		MaxBackoff:           10 * time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	}
+	tests := []struct {
+		name       string
+		statuses   []int
+		header     http.Header
+		timeout    time.Duration
+		maxBackoff time.Duration
+		wantStatus int
+		wantSent   int
+	}{{
+		name:       "succeeds after retries",
+		statuses:   []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+		wantStatus: http.StatusOK,
+		wantSent:   3,
+	}, {
+		name:       "gives up after max attempts",
+		statuses:   []int{http.StatusServiceUnavailable},
+		wantStatus: http.StatusServiceUnavailable,
+		wantSent:   3,
+	}, {
+		name:       "not retryable",
+		statuses:   []int{http.StatusBadGateway},
+		wantStatus: http.StatusBadGateway,
+		wantSent:   1,
+	}, {
+		name:       "retry after past the deadline",
+		statuses:   []int{http.StatusServiceUnavailable, http.StatusOK},
+		header:     http.Header{"Retry-After": {"120"}},
+		timeout:    time.Minute,
+		maxBackoff: 5 * time.Minute,
+		wantStatus: http.StatusServiceUnavailable,
+		wantSent:   1,
+	}, {
+		name:       "retry after capped at max backoff",
+		statuses:   []int{http.StatusServiceUnavailable, http.StatusOK},
+		header:     http.Header{"Retry-After": {"86400"}},
+		wantStatus: http.StatusOK,
+		wantSent:   2,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := newFakeSink(t)
+			defer sink.Close()
+			sink.statuses = tc.statuses
+			sink.header = tc.header
+
+			p := *policy
+			if tc.maxBackoff > 0 {
+				p.MaxBackoff = tc.maxBackoff
+			}
+			ra := &CloudSchedulerReceiveAdapter{
+				Sink:           sink.URL,
+				Retry:          &p,
+				RequestTimeout: tc.timeout,
+			}
+			w := httptest.NewRecorder()
+			ra.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+			if w.Code != tc.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tc.wantStatus)
+			}
+			if len(sink.requests) != tc.wantSent {
+				t.Errorf("Sink got %d events, want %d", len(sink.requests), tc.wantSent)
+			}
+		})
+	}
+}
+
+func TestServeHTTPSendsDeadLetters(t *testing.T) {
+	sink := newFakeSink(t)
+	defer sink.Close()
+	sink.statuses = []int{http.StatusServiceUnavailable}
+	deadLetters := newFakeSink(t)
+	defer deadLetters.Close()
+
+	ra := &CloudSchedulerReceiveAdapter{
+		Sink:           sink.URL,
+		DeadLetterSink: deadLetters.URL,
+		Retry: &RetryPolicy{
+			MaxAttempts:          2,
+			MinBackoff:           time.Millisecond,
+			MaxBackoff:           time.Millisecond,
+			RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+		},
+	}
+	w := httptest.NewRecorder()
+	ra.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("ServeHTTP() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if len(sink.requests) != 2 {
+		t.Errorf("Sink got %d events, want 2", len(sink.requests))
+	}
+	if len(deadLetters.requests) != 1 {
+		t.Fatalf("Dead letter sink got %d events, want 1", len(deadLetters.requests))
+	}
+	got := deadLetters.requests[0].Header
+	for h, want := range map[string]string{
+		"CE-X-deadlettersink":     `"` + sink.URL + `"`,
+		"CE-X-deadletterreason":   `"sink returned 503 Service Unavailable"`,
+		"CE-X-deadletterattempts": "2",
+		"CE-X-deadletterstatus":   "503",
+	} {
+		if v := got.Get(h); v != want {
+			t.Errorf("Header %s = %s, want %s", h, v, want)
+		}
+	}
+	if got, want := deadLetters.requests[0].Header.Get("CE-EventID"), sink.requests[0].Header.Get("CE-EventID"); got != want {
+		t.Errorf("Dead letter event ID = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiveadapter
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how events the Sink didn't accept are retried.
+type RetryPolicy struct {
+	// MaxAttempts is how many times an event is sent at most.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry, which doubles with
+	// every retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction by which the waits are randomly made shorter
+	// or longer.
+	Jitter float64
+	// RetryableStatusCodes are the statuses of the responses of the Sink
+	// that are retried. Failures to reach the Sink are always retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy is the RetryPolicy of sources that don't configure one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Second,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.2,
+	RetryableStatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// retryable returns true if sending an event that failed with the given
+// error may succeed when tried again.
+func (p *RetryPolicy) retryable(err error) bool {
+	switch e := err.(type) {
+	case *sinkError:
+		for _, code := range p.RetryableStatusCodes {
+			if e.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	case *url.Error:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns how long to wait before the given retry, starting at 1,
+// of an event that failed with the given error. The Retry-After of the
+// response of the Sink takes precedence, up to MaxBackoff, so that a Sink
+// can't hold up deliveries for longer than the policy allows.
+func (p *RetryPolicy) backoff(retry int, err error) time.Duration {
+	if se, ok := err.(*sinkError); ok && se.RetryAfter > 0 {
+		if se.RetryAfter > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return se.RetryAfter
+	}
+	wait := p.MinBackoff
+	for i := 1; i < retry && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait += time.Duration(float64(wait) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return wait
+}
+
+// withRetries calls send until it succeeds, fails with an error that isn't
+// retryable, or the policy gives up. Retries stop early when the wait would
+// exceed the deadline of the context. The number of attempts and the last
+// error are returned.
+func withRetries(ctx context.Context, p *RetryPolicy, send func() error) (int, error) {
+	attempts, err := 1, send()
+	if p == nil {
+		return attempts, err
+	}
+	for ; err != nil && attempts < p.MaxAttempts && p.retryable(err); attempts++ {
+		wait := p.backoff(attempts, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return attempts, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, err
+		case <-time.After(wait):
+		}
+		err = send()
+	}
+	return attempts, err
+}
+
+// retryAfter parses the Retry-After header of a response, which is either a
+// number of seconds or a date. Zero is returned if it's missing or invalid.
+func retryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -21,29 +21,48 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	duckapis "github.com/knative/pkg/apis"
+	"github.com/knative/pkg/apis/duck"
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
 	"github.com/knative/pkg/controller"
 	"github.com/knative/pkg/logging/logkey"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	appsv1informers "k8s.io/client-go/informers/apps/v1"
+	batchv1beta1informers "k8s.io/client-go/informers/batch/v1beta1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
-	"cloud.google.com/go/scheduler/apiv1beta1"
 	servingv1alpha1 "github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	servingclientset "github.com/knative/serving/pkg/client/clientset/versioned"
 	servinginformers "github.com/knative/serving/pkg/client/informers/externalversions/serving/v1alpha1"
 	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+	"github.com/vaikas-google/csr/pkg/backend"
 	clientset "github.com/vaikas-google/csr/pkg/client/clientset/versioned"
 	cloudschedulersourcescheme "github.com/vaikas-google/csr/pkg/client/clientset/versioned/scheme"
 	informers "github.com/vaikas-google/csr/pkg/client/informers/externalversions/cloudschedulersource/v1alpha1"
 	listers "github.com/vaikas-google/csr/pkg/client/listers/cloudschedulersource/v1alpha1"
+	"github.com/vaikas-google/csr/pkg/cron"
+	"github.com/vaikas-google/csr/pkg/receiveadapter"
 	"github.com/vaikas-google/csr/pkg/reconciler/cloudschedulersource/resources"
+	"github.com/vaikas-google/csr/pkg/tracker"
 	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	gstatus "google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -53,6 +72,14 @@ import (
 const (
 	controllerAgentName = "cloudschedulersource-controller"
 	finalizerName       = controllerAgentName
+
+	// resyncPeriod is how often the informers of the sinks resync.
+	resyncPeriod = 30 * time.Second
+
+	// subscriptionAckDeadline is how many seconds the Receive Adapter has
+	// to deliver an event pulled from Pub/Sub before it is redelivered. The
+	// Receive Adapter gives up a little before that.
+	subscriptionAckDeadline = 60
 )
 
 // Reconciler is the controller implementation for Cloudschedulersource resources
@@ -66,13 +93,32 @@ type Reconciler struct {
 	// We use dynamic client for Duck type related stuff.
 	dynamicClient dynamic.Interface
 
+	// sinkInformerFactory starts informers for the kinds of the sinks in
+	// use, which let the tracker know when a sink changes.
+	sinkInformerFactory duck.InformerFactory
+	// tracker enqueues the sources referring to a sink when it changes.
+	tracker tracker.Interface
+
 	// For dealing with Service.serving.knative.dev
 	servingClient   servingclientset.Interface
 	servingInformer servinginformers.ServiceInformer
 
+	// jobBackends manage the scheduled jobs backing the sources, keyed by
+	// the kind of backend.
+	jobBackends map[v1alpha1.SchedulerBackend]backend.JobBackend
+	// defaultBackend is used for the sources that don't specify one.
+	defaultBackend v1alpha1.SchedulerBackend
+	// pubSub manages the topics of the sources with a PubSubTarget, nil if
+	// Pub/Sub is not available.
+	pubSub backend.PubSub
+
 	// Receive Adapter Image.
 	raImage string
 
+	// recorder records Events about the sources, for example when their
+	// jobs had to be corrected.
+	recorder record.EventRecorder
+
 	// Sugared logger is easier to use but is not as performant as the
 	// raw logger. In performance critical paths, call logger.Desugar()
 	// and use the returned raw logger instead. In addition to the
@@ -99,19 +145,35 @@ func NewController(
 	cloudschedulersourceInformer informers.CloudSchedulerSourceInformer,
 	servingclientset servingclientset.Interface,
 	servingsourceInformer servinginformers.ServiceInformer,
+	cronJobInformer batchv1beta1informers.CronJobInformer,
+	deploymentInformer appsv1informers.DeploymentInformer,
+	jobBackends map[v1alpha1.SchedulerBackend]backend.JobBackend,
+	defaultBackend v1alpha1.SchedulerBackend,
+	pubSub backend.PubSub,
 	raImage string,
+	stopCh <-chan struct{},
 ) *controller.Impl {
 
 	// Enrich the logs with controller name
 	logger = logger.Named(controllerAgentName).With(zap.String(logkey.ControllerType, controllerAgentName))
 
+	// Send the Events recorded by the reconciler to the API server.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logger.Named("event-broadcaster").Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeclientset.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+
 	r := &Reconciler{
 		kubeclientset:                 kubeclientset,
 		dynamicClient:                 dynamicClient,
 		cloudschedulersourceclientset: cloudschedulersourceclientset,
 		cloudschedulersourcesLister:   cloudschedulersourceInformer.Lister(),
 		servingClient:                 servingclientset,
+		jobBackends:                   jobBackends,
+		defaultBackend:                defaultBackend,
+		pubSub:                        pubSub,
 		raImage:                       raImage,
+		recorder:                      recorder,
 		Logger:                        logger,
 	}
 	statsExporter, err := controller.NewStatsReporter(controllerAgentName)
@@ -136,6 +198,40 @@ func NewController(
 		DeleteFunc: impl.EnqueueControllerOf,
 	})
 
+	// Likewise for the CronJobs created for sources using the CronJob backend.
+	cronJobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    impl.EnqueueControllerOf,
+		UpdateFunc: controller.PassNew(impl.EnqueueControllerOf),
+		DeleteFunc: impl.EnqueueControllerOf,
+	})
+
+	// And the Receive Adapter Deployments of sources with a PubSubTarget.
+	deploymentInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    impl.EnqueueControllerOf,
+		UpdateFunc: controller.PassNew(impl.EnqueueControllerOf),
+		DeleteFunc: impl.EnqueueControllerOf,
+	})
+
+	// Sinks can be of any Addressable kind, so their informers are started
+	// as the sources that use them are reconciled. The sources track their
+	// sinks for a few resyncs, and renew that every time they're reconciled.
+	r.tracker = tracker.New(impl.EnqueueKey, 3*resyncPeriod)
+	r.sinkInformerFactory = &duck.CachedInformerFactory{
+		Delegate: &duck.EnqueueInformerFactory{
+			Delegate: &duck.TypedInformerFactory{
+				Client:       dynamicClient,
+				Type:         &duckv1alpha1.AddressableType{},
+				ResyncPeriod: resyncPeriod,
+				StopChannel:  stopCh,
+			},
+			EventHandler: cache.ResourceEventHandlerFuncs{
+				AddFunc:    r.tracker.OnChanged,
+				UpdateFunc: controller.PassNew(r.tracker.OnChanged),
+				DeleteFunc: r.tracker.OnChanged,
+			},
+		},
+	}
+
 	return impl
 }
 
@@ -161,6 +257,11 @@ func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
 	// Don't modify the informers copy
 	csr := original.DeepCopy()
 
+	// The source may have been created before the defaulting webhook was
+	// installed. This isn't persisted, but lets us rely on the defaults
+	// while reconciling.
+	csr.SetDefaults()
+
 	err = c.reconcileCloudSchedulerSource(ctx, csr)
 
 	if equality.Semantic.DeepEqual(original.Status, csr.Status) &&
@@ -181,25 +282,62 @@ func (c *Reconciler) reconcileCloudSchedulerSource(ctx context.Context, csr *v1a
 	// See if the source has been deleted.
 	deletionTimestamp := csr.DeletionTimestamp
 
+	csr.Status.InitializeConditions()
+
 	// First try to resolve the sink, and if not found mark as not resolved.
-	uri, err := GetSinkURI(c.dynamicClient, csr.Spec.Sink, csr.Namespace)
-	if err != nil {
-		// TODO: Update status appropriately
-		//		csr.Status.MarkNoSink("NotFound", "%s", err)
-		c.Logger.Infof("Couldn't resolve Sink URI: %s", err)
-		if deletionTimestamp == nil {
+	// Sources calling App Engine don't have one.
+	var uri string
+	if csr.Spec.AppEngineTarget == nil {
+		var err error
+		uri, err = GetSinkURI(c.dynamicClient, csr.Spec.Sink, csr.Namespace)
+		// A sink that doesn't exist yet is tracked too, so that the source
+		// is reconciled once it is created.
+		if err := c.trackSink(csr, csr.Spec.Sink, !isUnknownResource(err)); err != nil {
+			c.Logger.Infof("Unable to track the sink: %s", err)
+		}
+		if err != nil {
+			csr.Status.MarkNoSink("NotFound", "%s", err)
+			c.Logger.Infof("Couldn't resolve Sink URI: %s", err)
+			if deletionTimestamp == nil {
+				return err
+			}
+			// we don't care about the URI if we're deleting, so carry on...
+			uri = ""
+		} else {
+			csr.Status.MarkSink(uri)
+		}
+		c.Logger.Infof("Resolved Sink URI to %q", uri)
+	}
+
+	var deadLetterURI string
+	if csr.Spec.DeadLetterSink != nil && csr.Spec.AppEngineTarget == nil {
+		var err error
+		deadLetterURI, err = GetSinkURI(c.dynamicClient, csr.Spec.DeadLetterSink, csr.Namespace)
+		if err := c.trackSink(csr, csr.Spec.DeadLetterSink, !isUnknownResource(err)); err != nil {
+			c.Logger.Infof("Unable to track the dead letter sink: %s", err)
+		}
+		if err != nil && deletionTimestamp == nil {
+			csr.Status.MarkNoDeadLetterSink("NotFound", "%s", err)
+			c.Logger.Infof("Couldn't resolve Dead Letter Sink URI: %s", err)
 			return err
 		}
-		// we don't care about the URI if we're deleting, so carry on...
-		uri = ""
+		c.Logger.Infof("Resolved Dead Letter Sink URI to %q", deadLetterURI)
 	}
-	c.Logger.Infof("Resolved Sink URI to %q", uri)
 
 	if deletionTimestamp != nil {
-		err := c.deleteJob(csr)
-		if err != nil {
-			c.Logger.Infof("Unable to delete the Job: %s", err)
-			return err
+		// CronJobs are owned by the source, so they get garbage collected.
+		if c.backendKind(csr) != v1alpha1.SchedulerBackendCronJob {
+			err := c.deleteJob(csr)
+			if err != nil {
+				c.Logger.Infof("Unable to delete the Job: %s", err)
+				return err
+			}
+		}
+		if csr.Spec.PubSubTarget != nil {
+			if err := c.deleteTopic(csr); err != nil {
+				c.Logger.Infof("Unable to delete the Topic: %s", err)
+				return err
+			}
 		}
 		c.removeFinalizer(csr)
 		return nil
@@ -207,100 +345,393 @@ func (c *Reconciler) reconcileCloudSchedulerSource(ctx context.Context, csr *v1a
 
 	c.addFinalizer(csr)
 
-	csr.Status.SinkURI = uri
-
-	// Make sure Service is in the state we expect it to be in.
-	ksvc, err := c.reconcileService(csr)
-	if err != nil {
-		// TODO: Update status appropriately
-		c.Logger.Infof("Failed to reconcile service: %s", err)
-		return err
+	if csr.Spec.AppEngineTarget != nil {
+		csr.Status.MarkSinkNotRequired()
+		csr.Status.EventType, csr.Status.EventSource = "", ""
+	} else {
+		csr.Status.MarkDeadLetterSink(deadLetterURI)
+		csr.Status.EventType, csr.Status.EventSource = c.eventAttributes(csr)
 	}
-	c.Logger.Infof("Reconciled service: %+v", ksvc)
 
-	if ksvc.Status.Domain == "" {
-		// TODO: Update status appropriately
-		c.Logger.Infof("No domain configured for service, bailing...")
-		return fmt.Errorf("no domain configured for service")
+	if c.backendKind(csr) == v1alpha1.SchedulerBackendCronJob {
+		// The CronJob's pods send the events to the sink themselves, so
+		// there is no Receive Adapter Service to wait for.
+		csr.Status.MarkServiceReady()
+
+		cronJob, err := c.reconcileCronJob(csr)
+		if err != nil {
+			csr.Status.MarkJobFailed("CronJobReconcileFailed", "Failed to reconcile CronJob: %s", err)
+			c.Logger.Infof("Failed to reconcile CronJob: %s", err)
+			return err
+		}
+		c.Logger.Infof("Reconciled cronjob: %+v", cronJob)
+		csr.Status.MarkJob(fmt.Sprintf("namespaces/%s/cronjobs/%s", cronJob.Namespace, cronJob.Name))
+		csr.Status.JobState = schedulerpb.Job_ENABLED.String()
+		if cronJobSuspended(cronJob) {
+			csr.Status.JobState = schedulerpb.Job_PAUSED.String()
+		}
+		return nil
 	}
 
-	url := fmt.Sprintf("http://%s/", ksvc.Status.Domain)
-	c.Logger.Infof("using %s as a cluster sink", url)
+	var target string
+	switch {
+	case csr.Spec.PubSubTarget != nil:
+		topic, err := c.reconcilePubSub(ctx, csr)
+		if err != nil {
+			return err
+		}
+		target = topic
+	case csr.Spec.AppEngineTarget != nil:
+		if kind := c.backendKind(csr); kind != v1alpha1.SchedulerBackendCloudScheduler {
+			err := fmt.Errorf("appEngineTarget is not supported by the %s backend", kind)
+			csr.Status.MarkJobFailed("JobReconcileFailed", "%s", err)
+			return err
+		}
+		// App Engine is called directly, so there is no Receive Adapter.
+		csr.Status.MarkServiceReady()
+	default:
+		// Make sure Service is in the state we expect it to be in.
+		ksvc, err := c.reconcileService(csr)
+		if err != nil {
+			csr.Status.MarkServiceNotReady("ServiceReconcileFailed", "Failed to reconcile Receive Adapter Service: %s", err)
+			c.Logger.Infof("Failed to reconcile service: %s", err)
+			return err
+		}
+		c.Logger.Infof("Reconciled service: %+v", ksvc)
 
-	job, err := c.reconcileJob(csr.Name, &csr.Spec, url)
+		if ksvc.Status.Domain == "" {
+			csr.Status.MarkServiceNotReady("ServiceDomainMissing", "Receive Adapter Service %q has no domain yet", ksvc.Name)
+			c.Logger.Infof("No domain configured for service, bailing...")
+			return fmt.Errorf("no domain configured for service")
+		}
+		csr.Status.MarkServiceReady()
+
+		domain := ksvc.Status.Domain
+		if c.backendKind(csr) == v1alpha1.SchedulerBackendInCluster && ksvc.Status.DomainInternal != "" {
+			// The in-cluster backend doesn't need to go through the ingress.
+			domain = ksvc.Status.DomainInternal
+		}
+		target = fmt.Sprintf("http://%s/", domain)
+		c.Logger.Infof("using %s as a cluster sink", target)
+	}
+
+	job, err := c.reconcileJob(csr, target)
 	if err != nil {
-		// TODO: Update status with this...
+		csr.Status.MarkJobFailed("JobReconcileFailed", "Failed to reconcile Cloud Scheduler Job: %s", err)
 		c.Logger.Infof("Failed to reconcile Job: %s", err)
 		return err
 	}
 
 	c.Logger.Infof("Reconciled job: %+v", job)
-	csr.Status.Job = job.Name
+	csr.Status.JobState = job.State.String()
+	if job.State == schedulerpb.Job_UPDATE_FAILED {
+		csr.Status.MarkJobFailed("JobUpdateFailed", "The last update of Cloud Scheduler Job %q failed", job.Name)
+		return fmt.Errorf("job %q is in state %s", job.Name, job.State)
+	}
+	csr.Status.MarkJob(job.Name)
 
 	return nil
 }
 
+// reconcilePubSub makes sure the Pub/Sub topic and subscription of the given
+// source, and the Receive Adapter Deployment pulling from it, exist. The
+// name of the topic is returned.
+func (c *Reconciler) reconcilePubSub(ctx context.Context, csr *v1alpha1.CloudSchedulerSource) (string, error) {
+	if kind := c.backendKind(csr); kind != v1alpha1.SchedulerBackendCloudScheduler {
+		err := fmt.Errorf("pubsubTarget is not supported by the %s backend", kind)
+		csr.Status.MarkJobFailed("TopicReconcileFailed", "%s", err)
+		return "", err
+	}
+	topic, subscription, err := c.reconcileTopic(ctx, csr)
+	if err != nil {
+		csr.Status.MarkJobFailed("TopicReconcileFailed", "Failed to reconcile Pub/Sub topic: %s", err)
+		c.Logger.Infof("Failed to reconcile topic: %s", err)
+		return "", err
+	}
+	csr.Status.Topic = topic
+
+	deployment, err := c.reconcileDeployment(csr, subscription)
+	if err != nil {
+		csr.Status.MarkServiceNotReady("DeploymentReconcileFailed", "Failed to reconcile Receive Adapter Deployment: %s", err)
+		c.Logger.Infof("Failed to reconcile deployment: %s", err)
+		return "", err
+	}
+	c.Logger.Infof("Reconciled deployment: %+v", deployment)
+
+	// The subscription keeps the events until the Receive Adapter is up,
+	// so the job doesn't need to wait for it.
+	if deployment.Status.AvailableReplicas == 0 {
+		csr.Status.MarkServiceNotReady("DeploymentUnavailable", "Receive Adapter Deployment %q has no available replicas yet", deployment.Name)
+	} else {
+		csr.Status.MarkServiceReady()
+	}
+	return topic, nil
+}
+
+// reconcileTopic makes sure the Pub/Sub topic of the given source, and the
+// subscription of its Receive Adapter, exist. Their names are returned.
+func (c *Reconciler) reconcileTopic(ctx context.Context, csr *v1alpha1.CloudSchedulerSource) (string, string, error) {
+	if c.pubSub == nil {
+		return "", "", fmt.Errorf("Pub/Sub is not available")
+	}
+	topic, subscription := topicNames(csr)
+	_, err := c.pubSub.CreateTopic(ctx, &pubsubpb.Topic{Name: topic})
+	if err != nil && gstatus.Code(err) != codes.AlreadyExists {
+		return "", "", err
+	}
+	_, err = c.pubSub.CreateSubscription(ctx, &pubsubpb.Subscription{
+		Name:               subscription,
+		Topic:              topic,
+		AckDeadlineSeconds: subscriptionAckDeadline,
+	})
+	if err != nil && gstatus.Code(err) != codes.AlreadyExists {
+		return "", "", err
+	}
+	return topic, subscription, nil
+}
+
+// deleteTopic deletes the Pub/Sub subscription and topic of the given
+// source, if they exist.
+func (c *Reconciler) deleteTopic(csr *v1alpha1.CloudSchedulerSource) error {
+	topic, subscription := topicNames(csr)
+	if c.pubSub == nil {
+		// Don't keep the source around forever because of it.
+		c.Logger.Warnf("Pub/Sub is not available, leaving topic %q and subscription %q behind", topic, subscription)
+		return nil
+	}
+	ctx := context.Background()
+	err := c.pubSub.DeleteSubscription(ctx, &pubsubpb.DeleteSubscriptionRequest{Subscription: subscription})
+	if err != nil && gstatus.Code(err) != codes.NotFound {
+		return err
+	}
+	err = c.pubSub.DeleteTopic(ctx, &pubsubpb.DeleteTopicRequest{Topic: topic})
+	if err != nil && gstatus.Code(err) != codes.NotFound {
+		return err
+	}
+	return nil
+}
+
+// topicNames returns the full names of the Pub/Sub topic and subscription
+// of the given source.
+func topicNames(csr *v1alpha1.CloudSchedulerSource) (string, string) {
+	id := resources.TopicID(csr)
+	project := csr.Spec.GoogleCloudProject
+	return fmt.Sprintf("projects/%s/topics/%s", project, id),
+		fmt.Sprintf("projects/%s/subscriptions/%s", project, id)
+}
+
+func (c *Reconciler) reconcileDeployment(csr *v1alpha1.CloudSchedulerSource, subscription string) (*appsv1.Deployment, error) {
+	deploymentClient := c.kubeclientset.AppsV1().Deployments(csr.Namespace)
+	desired := resources.MakeDeployment(csr, c.raImage, subscription)
+	existing, err := deploymentClient.Get(csr.Name, v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		c.Logger.Infof("Creating deployment %+v", desired)
+		return deploymentClient.Create(desired)
+	} else if err != nil {
+		return nil, err
+	}
+	if !v1.IsControlledBy(existing, csr) {
+		return nil, fmt.Errorf("deployment %q is not owned by CloudSchedulerSource %q", existing.Name, csr.Name)
+	}
+
+	if deploymentChanged(existing, desired) {
+		existingPod := &existing.Spec.Template.Spec
+		desiredPod := desired.Spec.Template.Spec
+		existingPod.ServiceAccountName = desiredPod.ServiceAccountName
+		existingPod.Containers = desiredPod.Containers
+		existingPod.Volumes = desiredPod.Volumes
+		c.Logger.Infof("Updating deployment %+v", existing)
+		return deploymentClient.Update(existing)
+	}
+	return existing, nil
+}
+
+// deploymentChanged returns true if the fields MakeDeployment sets differ
+// between the existing and desired Deployments.
+func deploymentChanged(existing, desired *appsv1.Deployment) bool {
+	existingPod := existing.Spec.Template.Spec
+	desiredPod := desired.Spec.Template.Spec
+	if existingPod.ServiceAccountName != desiredPod.ServiceAccountName ||
+		len(existingPod.Containers) != len(desiredPod.Containers) ||
+		len(existingPod.Volumes) != len(desiredPod.Volumes) {
+		return true
+	}
+	for i := range desiredPod.Containers {
+		e, d := existingPod.Containers[i], desiredPod.Containers[i]
+		if e.Image != d.Image ||
+			!equality.Semantic.DeepEqual(e.Args, d.Args) ||
+			!equality.Semantic.DeepEqual(e.Env, d.Env) ||
+			!equality.Semantic.DeepEqual(e.VolumeMounts, d.VolumeMounts) {
+			return true
+		}
+	}
+	for i := range desiredPod.Volumes {
+		e, d := existingPod.Volumes[i], desiredPod.Volumes[i]
+		if e.Name != d.Name || e.Secret == nil || d.Secret == nil || e.Secret.SecretName != d.Secret.SecretName {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Reconciler) reconcileService(csr *v1alpha1.CloudSchedulerSource) (*servingv1alpha1.Service, error) {
 	svcClient := c.servingClient.ServingV1alpha1().Services(csr.Namespace)
+	desired := resources.MakeService(csr, c.raImage)
 	existing, err := svcClient.Get(csr.Name, v1.GetOptions{})
-	if err == nil {
-		// TODO: Handle any updates...
-		c.Logger.Infof("Found existing service: %+v", existing)
-		return existing, nil
+	if errors.IsNotFound(err) {
+		c.Logger.Infof("Creating service %+v", desired)
+		return svcClient.Create(desired)
+	} else if err != nil {
+		return nil, err
+	}
+	c.Logger.Infof("Found existing service: %+v", existing)
+	if !v1.IsControlledBy(existing, csr) {
+		return nil, fmt.Errorf("service %q is not owned by CloudSchedulerSource %q", existing.Name, csr.Name)
+	}
+
+	if serviceChanged(existing, desired) {
+		// Only replace the fields MakeService sets, so that the defaults
+		// filled in by Knative Serving don't cause a new revision.
+		if existing.Spec.RunLatest == nil {
+			existing.Spec = desired.Spec
+		} else {
+			existingRev := &existing.Spec.RunLatest.Configuration.RevisionTemplate.Spec
+			desiredRev := desired.Spec.RunLatest.Configuration.RevisionTemplate.Spec
+			existingRev.ServiceAccountName = desiredRev.ServiceAccountName
+			existingRev.Container.Image = desiredRev.Container.Image
+			existingRev.Container.Env = desiredRev.Container.Env
+			existingRev.Container.Args = desiredRev.Container.Args
+		}
+		c.Logger.Infof("Updating service %+v", existing)
+		return svcClient.Update(existing)
 	}
+	return existing, nil
+}
+
+// serviceChanged returns true if the fields MakeService sets differ between
+// the existing and desired Services, for example because the sink moved.
+func serviceChanged(existing, desired *servingv1alpha1.Service) bool {
+	if existing.Spec.RunLatest == nil {
+		return true
+	}
+	e := existing.Spec.RunLatest.Configuration.RevisionTemplate.Spec
+	d := desired.Spec.RunLatest.Configuration.RevisionTemplate.Spec
+	return e.ServiceAccountName != d.ServiceAccountName ||
+		e.Container.Image != d.Container.Image ||
+		!equality.Semantic.DeepEqual(e.Container.Env, d.Container.Env) ||
+		!equality.Semantic.DeepEqual(e.Container.Args, d.Container.Args)
+}
+
+func (c *Reconciler) reconcileCronJob(csr *v1alpha1.CloudSchedulerSource) (*batchv1beta1.CronJob, error) {
+	if csr.Spec.TimeZone != v1alpha1.DefaultTimeZone {
+		return nil, fmt.Errorf("time zone %q is not supported, CronJobs use the time zone of the cluster", csr.Spec.TimeZone)
+	}
+	// The webhook rejects these too, but it may not be installed.
+	if _, err := cron.Parse(csr.Spec.Schedule); err != nil {
+		return nil, err
+	}
+	if cron.IsInterval(csr.Spec.Schedule) {
+		return nil, fmt.Errorf("schedule %q is not supported, CronJobs don't support intervals", csr.Spec.Schedule)
+	}
+
+	cronJobClient := c.kubeclientset.BatchV1beta1().CronJobs(csr.Namespace)
+	desired := resources.MakeCronJob(csr, c.raImage)
+	existing, err := cronJobClient.Get(desired.Name, v1.GetOptions{})
 	if errors.IsNotFound(err) {
-		ksvc := resources.MakeService(csr, c.raImage)
-		c.Logger.Infof("Creating service %+v", ksvc)
-		return c.servingClient.ServingV1alpha1().Services(csr.Namespace).Create(ksvc)
+		c.Logger.Infof("Creating cronjob %+v", desired)
+		return cronJobClient.Create(desired)
+	} else if err != nil {
+		return nil, err
+	}
+	if !v1.IsControlledBy(existing, csr) {
+		return nil, fmt.Errorf("cronjob %q is not owned by CloudSchedulerSource %q", existing.Name, csr.Name)
+	}
+
+	if cronJobChanged(existing, desired) {
+		existing.Spec.Schedule = desired.Spec.Schedule
+		existing.Spec.Suspend = desired.Spec.Suspend
+		existing.Spec.JobTemplate = desired.Spec.JobTemplate
+		c.Logger.Infof("Updating cronjob %+v", existing)
+		return cronJobClient.Update(existing)
 	}
-	return nil, err
+	return existing, nil
 }
 
-func (c *Reconciler) reconcileJob(name string, spec *v1alpha1.CloudSchedulerSourceSpec, target string) (*schedulerpb.Job, error) {
-	parent := fmt.Sprintf("projects/%s/locations/%s", spec.GoogleCloudProject, spec.Location)
-	jobName := fmt.Sprintf("%s/jobs/%s", parent, name)
+// cronJobChanged returns true if the fields MakeCronJob sets differ between
+// the existing and desired CronJobs. The rest are left alone, since they may
+// have been defaulted by the API server.
+func cronJobChanged(existing, desired *batchv1beta1.CronJob) bool {
+	if existing.Spec.Schedule != desired.Spec.Schedule ||
+		cronJobSuspended(existing) != cronJobSuspended(desired) {
+		return true
+	}
+	existingPod := existing.Spec.JobTemplate.Spec.Template.Spec
+	desiredPod := desired.Spec.JobTemplate.Spec.Template.Spec
+	if existingPod.ServiceAccountName != desiredPod.ServiceAccountName ||
+		len(existingPod.Containers) != len(desiredPod.Containers) {
+		return true
+	}
+	for i := range desiredPod.Containers {
+		e, d := existingPod.Containers[i], desiredPod.Containers[i]
+		if e.Image != d.Image ||
+			!equality.Semantic.DeepEqual(e.Args, d.Args) ||
+			!equality.Semantic.DeepEqual(e.Env, d.Env) {
+			return true
+		}
+	}
+	return false
+}
 
-	c.Logger.Infof("Parent: %q Job: %q", parent, jobName)
+// cronJobSuspended returns true if the given CronJob is suspended.
+func cronJobSuspended(cronJob *batchv1beta1.CronJob) bool {
+	return cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+}
 
-	ctx := context.Background()
-	csc, err := scheduler.NewCloudSchedulerClient(ctx)
+func (c *Reconciler) reconcileJob(csr *v1alpha1.CloudSchedulerSource, target string) (*schedulerpb.Job, error) {
+	jobBackend, err := c.backendFor(csr)
 	if err != nil {
 		return nil, err
 	}
+	parent := c.jobParent(csr)
+	jobName := c.jobName(csr)
 
+	c.Logger.Infof("Parent: %q Job: %q", parent, jobName)
+
+	ctx := context.Background()
 	getReq := &schedulerpb.GetJobRequest{
 		Name: jobName,
 	}
 
-	existing, err := csc.GetJob(ctx, getReq)
+	existing, err := jobBackend.GetJob(ctx, getReq)
 	if err == nil {
-		c.Logger.Infof("Found existing job as: %+v", existing)
-
-		existingHttpTarget := existing.GetHttpTarget()
-		if existingHttpTarget == nil {
-			return nil, fmt.Errorf("missing http target in the existing scheduler proto: %+v", existing)
+		// The existing job is ours, so it's safe to clean up the one
+		// we had before, if any.
+		if err := c.deletePreviousJob(ctx, jobBackend, csr, jobName); err != nil {
+			return nil, err
 		}
 
-		updated := createJobProto(jobName, spec, target)
-		updatedHttpTarget := updated.GetHttpTarget()
-		if updatedHttpTarget == nil {
-			return nil, fmt.Errorf("missing http target in the updated scheduler proto: %+v", updated)
+		c.Logger.Infof("Found existing job as: %+v", existing)
+
+		desired := createJobProto(jobName, csr, target)
+		paths := jobDrift(existing, desired)
+		if len(paths) == 0 && existing.State == schedulerpb.Job_UPDATE_FAILED {
+			// Cloud Scheduler recovers jobs whose update failed once an
+			// update succeeds.
+			paths = allJobPaths(desired)
 		}
-		if updated.Schedule != existing.Schedule ||
-			updated.TimeZone != existing.TimeZone ||
-			bytes.Compare(updatedHttpTarget.Body, existingHttpTarget.Body) != 0 ||
-			updatedHttpTarget.HttpMethod != existingHttpTarget.HttpMethod {
+		if len(paths) > 0 {
 			req := &schedulerpb.UpdateJobRequest{
-				Job: updated,
+				Job:        desired,
+				UpdateMask: &field_mask.FieldMask{Paths: paths},
 			}
-			c.Logger.Info("Updating Job spec with %+v", req)
-			resp, err := csc.UpdateJob(ctx, req)
+			c.Logger.Infof("Updating Job spec with %+v", req)
+			existing, err = jobBackend.UpdateJob(ctx, req)
 			if err != nil {
 				return nil, err
 			}
-			return resp, nil
+			c.recorder.Eventf(csr, corev1.EventTypeNormal, "JobUpdated", "Updated %s of job %q to match the source", strings.Join(paths, ", "), jobName)
 		}
-		return existing, nil
+		return c.reconcileJobState(ctx, jobBackend, csr, existing)
 	}
 
 	if st, ok := gstatus.FromError(err); !ok {
@@ -312,83 +743,394 @@ func (c *Reconciler) reconcileJob(name string, spec *v1alpha1.CloudSchedulerSour
 
 	req := &schedulerpb.CreateJobRequest{
 		Parent: parent,
-		Job:    createJobProto(jobName, spec, target),
+		Job:    createJobProto(jobName, csr, target),
 	}
 
 	c.Logger.Infof("Creating job as: %+v", req)
-	resp, err := csc.CreateJob(ctx, req)
+	resp, err := jobBackend.CreateJob(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	// TODO: Use resp.
 	c.Logger.Infof("Created job %+v", resp)
-	return resp, nil
-}
 
-func createJobProto(jobName string, spec *v1alpha1.CloudSchedulerSourceSpec, target string) *schedulerpb.Job {
-	// If no timezone specified, use UTC
-	timezone := "UTC"
-	if spec.TimeZone != "" {
-		timezone = spec.TimeZone
+	// Now that the new job exists, remove the one we had before, for example
+	// one named by an older version of the controller.
+	if err := c.deletePreviousJob(ctx, jobBackend, csr, jobName); err != nil {
+		return nil, err
 	}
+	return c.reconcileJobState(ctx, jobBackend, csr, resp)
+}
 
-	// For method, default to POST, otherwise use what's specified and look up the value for it.
-	HttpMethod := schedulerpb.HttpMethod_POST
-	if spec.HTTPMethod != "" {
-		if m, ok := schedulerpb.HttpMethod_value[spec.HTTPMethod]; ok {
-			HttpMethod = schedulerpb.HttpMethod(m)
+// reconcileJobState pauses or resumes the given job, depending on whether
+// the source is suspended. The job is returned in its new state.
+func (c *Reconciler) reconcileJobState(ctx context.Context, jobBackend backend.JobBackend, csr *v1alpha1.CloudSchedulerSource, job *schedulerpb.Job) (*schedulerpb.Job, error) {
+	switch {
+	case csr.Spec.Suspend && job.State == schedulerpb.Job_ENABLED:
+		c.Logger.Infof("Pausing job %q", job.Name)
+		paused, err := jobBackend.PauseJob(ctx, &schedulerpb.PauseJobRequest{Name: job.Name})
+		if err != nil {
+			return nil, err
+		}
+		c.recorder.Eventf(csr, corev1.EventTypeNormal, "JobPaused", "Paused job %q", job.Name)
+		return paused, nil
+	case !csr.Spec.Suspend && job.State == schedulerpb.Job_PAUSED:
+		c.Logger.Infof("Resuming job %q", job.Name)
+		resumed, err := jobBackend.ResumeJob(ctx, &schedulerpb.ResumeJobRequest{Name: job.Name})
+		if err != nil {
+			return nil, err
 		}
+		c.recorder.Eventf(csr, corev1.EventTypeNormal, "JobResumed", "Resumed job %q", job.Name)
+		return resumed, nil
 	}
+	return job, nil
+}
 
-	httpTarget := &schedulerpb.Job_HttpTarget{
-		HttpTarget: &schedulerpb.HttpTarget{
-			Uri:        target,
-			HttpMethod: HttpMethod,
-		},
+// trackSink makes sure changes to the given sink of the given source, such
+// as its address, enqueue the source. With watch, the informer for the kind
+// of the sink is started if needed, which blocks until it has synced, so the
+// kind must exist.
+func (c *Reconciler) trackSink(csr *v1alpha1.CloudSchedulerSource, sink *corev1.ObjectReference, watch bool) error {
+	if sink == nil || csr.DeletionTimestamp != nil {
+		return nil
 	}
-	if spec.Body != "" {
-		httpTarget.HttpTarget.Body = []byte(spec.Body)
+	ref := *sink
+	ref.Namespace = csr.Namespace
+	if err := c.tracker.Track(ref, csr); err != nil {
+		return err
+	}
+	if !watch {
+		return nil
 	}
+	// Start watching the kind of the sink, if we aren't already.
+	_, _, err := c.sinkInformerFactory.Get(duckapis.KindToResource(ref.GroupVersionKind()))
+	return err
+}
+
+// deletePreviousJob deletes the job recorded in the status of the given
+// source if it differs from the job the source should have.
+func (c *Reconciler) deletePreviousJob(ctx context.Context, jobBackend backend.JobBackend, csr *v1alpha1.CloudSchedulerSource, jobName string) error {
+	previous := csr.Status.Job
+	if previous == "" || previous == jobName || !strings.Contains(previous, "/jobs/") {
+		return nil
+	}
+	c.Logger.Infof("Deleting previous job %q, replaced by %q", previous, jobName)
+	return deleteJobNamed(ctx, jobBackend, previous)
+}
+
+func createJobProto(jobName string, csr *v1alpha1.CloudSchedulerSource, target string) *schedulerpb.Job {
+	spec := &csr.Spec
+	HttpMethod := schedulerpb.HttpMethod(schedulerpb.HttpMethod_value[spec.HTTPMethod])
 
 	job := &schedulerpb.Job{
-		Name:     jobName,
-		Schedule: spec.Schedule,
-		TimeZone: timezone,
-		Target:   httpTarget,
+		Name:        jobName,
+		Description: jobDescription(csr),
+		Schedule:    cron.Expand(spec.Schedule),
+		TimeZone:    spec.TimeZone,
+	}
+	switch {
+	case spec.AppEngineTarget != nil:
+		ae := spec.AppEngineTarget
+		appEngineTarget := &schedulerpb.Job_AppEngineHttpTarget{
+			AppEngineHttpTarget: &schedulerpb.AppEngineHttpTarget{
+				HttpMethod:  HttpMethod,
+				RelativeUri: ae.RelativeURI,
+				Headers:     ae.Headers,
+			},
+		}
+		if ae.Service != "" || ae.Version != "" || ae.Instance != "" {
+			appEngineTarget.AppEngineHttpTarget.AppEngineRouting = &schedulerpb.AppEngineRouting{
+				Service:  ae.Service,
+				Version:  ae.Version,
+				Instance: ae.Instance,
+			}
+		}
+		if spec.Body != "" {
+			appEngineTarget.AppEngineHttpTarget.Body = []byte(spec.Body)
+		}
+		job.Target = appEngineTarget
+	case spec.PubSubTarget != nil:
+		// The target is the name of the topic.
+		pubsubTarget := &schedulerpb.Job_PubsubTarget{
+			PubsubTarget: &schedulerpb.PubsubTarget{
+				TopicName:  target,
+				Attributes: spec.PubSubTarget.Attributes,
+			},
+		}
+		if spec.Body != "" {
+			pubsubTarget.PubsubTarget.Data = []byte(spec.Body)
+		}
+		job.Target = pubsubTarget
+	default:
+		httpTarget := &schedulerpb.Job_HttpTarget{
+			HttpTarget: &schedulerpb.HttpTarget{
+				Uri:        target,
+				HttpMethod: HttpMethod,
+				Headers:    spec.Headers,
+			},
+		}
+		if spec.Body != "" {
+			httpTarget.HttpTarget.Body = []byte(spec.Body)
+		}
+		if spec.OIDCToken != nil {
+			// Cloud Scheduler would default the audience to the URI too,
+			// setting it keeps the job from drifting.
+			audience := spec.OIDCToken.Audience
+			if audience == "" {
+				audience = target
+			}
+			httpTarget.HttpTarget.AuthorizationHeader = &schedulerpb.HttpTarget_OidcToken{
+				OidcToken: &schedulerpb.OidcToken{
+					ServiceAccountEmail: spec.OIDCToken.ServiceAccountEmail,
+					Audience:            audience,
+				},
+			}
+		}
+		job.Target = httpTarget
+	}
+	if spec.RetryConfig != nil {
+		job.RetryConfig = retryConfigProto(spec.RetryConfig)
 	}
 	return job
 }
 
-func (c *Reconciler) deleteJob(csr *v1alpha1.CloudSchedulerSource) error {
-	parent := fmt.Sprintf("projects/%s/locations/%s", csr.Spec.GoogleCloudProject, csr.Spec.Location)
-	jobName := fmt.Sprintf("%s/jobs/%s", parent, csr.Name)
+// jobDescription returns the description of the job of the given source,
+// which tells where the job comes from when looking at it in the console.
+func jobDescription(csr *v1alpha1.CloudSchedulerSource) string {
+	return fmt.Sprintf("Managed by CloudSchedulerSource %s/%s", csr.Namespace, csr.Name)
+}
 
-	c.Logger.Infof("Parent: %q Job: %q", parent, jobName)
+// allJobPaths returns the update mask paths of all the fields the
+// reconciler sets on the given job.
+func allJobPaths(job *schedulerpb.Job) []string {
+	target := "http_target"
+	switch {
+	case job.GetPubsubTarget() != nil:
+		target = "pubsub_target"
+	case job.GetAppEngineHttpTarget() != nil:
+		target = "app_engine_http_target"
+	}
+	return []string{"description", "schedule", "time_zone", target, "retry_config"}
+}
 
-	ctx := context.Background()
-	csc, err := scheduler.NewCloudSchedulerClient(ctx)
+// jobDrift returns the update mask paths of the fields of the existing job
+// that differ from the desired job, for example because the job was edited
+// in the console.
+func jobDrift(existing, desired *schedulerpb.Job) []string {
+	var paths []string
+	if existing.Description != desired.Description {
+		paths = append(paths, "description")
+	}
+	if existing.Schedule != desired.Schedule {
+		paths = append(paths, "schedule")
+	}
+	if existing.TimeZone != desired.TimeZone {
+		paths = append(paths, "time_zone")
+	}
+	if httpTargetChanged(existing.GetHttpTarget(), desired.GetHttpTarget()) {
+		paths = append(paths, "http_target")
+	}
+	if pubsubTargetChanged(existing.GetPubsubTarget(), desired.GetPubsubTarget()) {
+		paths = append(paths, "pubsub_target")
+	}
+	if appEngineTargetChanged(existing.GetAppEngineHttpTarget(), desired.GetAppEngineHttpTarget()) {
+		paths = append(paths, "app_engine_http_target")
+	}
+	if retryConfigChanged(existing.RetryConfig, desired.RetryConfig) {
+		paths = append(paths, "retry_config")
+	}
+	return paths
+}
+
+// pubsubTargetChanged returns true if the existing and desired Pub/Sub
+// targets differ.
+func pubsubTargetChanged(existing, desired *schedulerpb.PubsubTarget) bool {
+	if existing == nil || desired == nil {
+		return existing != desired
+	}
+	return existing.TopicName != desired.TopicName ||
+		!bytes.Equal(existing.Data, desired.Data) ||
+		!equality.Semantic.DeepEqual(existing.Attributes, desired.Attributes)
+}
+
+// defaultTargetHeaders are the headers Cloud Scheduler may add to the HTTP
+// targets of jobs that don't set them.
+var defaultTargetHeaders = sets.NewString("User-Agent", "Content-Type")
+
+// httpTargetChanged returns true if the existing and desired HTTP targets
+// differ.
+func httpTargetChanged(existing, desired *schedulerpb.HttpTarget) bool {
+	if existing == nil || desired == nil {
+		return existing != desired
+	}
+	return existing.Uri != desired.Uri ||
+		existing.HttpMethod != desired.HttpMethod ||
+		!bytes.Equal(existing.Body, desired.Body) ||
+		headersChanged(existing.Headers, desired.Headers) ||
+		!proto.Equal(existing.GetOidcToken(), desired.GetOidcToken())
+}
+
+// appEngineTargetChanged returns true if the existing and desired App Engine
+// targets differ. The host Cloud Scheduler fills in from the routing is
+// ignored, like the default headers.
+func appEngineTargetChanged(existing, desired *schedulerpb.AppEngineHttpTarget) bool {
+	if existing == nil || desired == nil {
+		return existing != desired
+	}
+	routing := func(t *schedulerpb.AppEngineHttpTarget) [3]string {
+		r := t.GetAppEngineRouting()
+		return [3]string{r.GetService(), r.GetVersion(), r.GetInstance()}
+	}
+	return existing.RelativeUri != desired.RelativeUri ||
+		existing.HttpMethod != desired.HttpMethod ||
+		routing(existing) != routing(desired) ||
+		!bytes.Equal(existing.Body, desired.Body) ||
+		headersChanged(existing.Headers, desired.Headers)
+}
+
+// headersChanged returns true if the existing and desired headers of a
+// target differ. The headers Cloud Scheduler fills in are ignored unless the
+// desired target sets them.
+func headersChanged(existing, desired map[string]string) bool {
+	headers := make(map[string]string, len(existing))
+	for k, v := range existing {
+		if _, ok := desired[k]; !ok && defaultTargetHeaders.Has(k) {
+			continue
+		}
+		headers[k] = v
+	}
+	// Unlike reflect.DeepEqual, nil and empty maps are equal here.
+	return !equality.Semantic.DeepEqual(headers, desired)
+}
+
+func retryConfigProto(rc *v1alpha1.RetryConfig) *schedulerpb.RetryConfig {
+	pb := &schedulerpb.RetryConfig{
+		RetryCount:   rc.RetryCount,
+		MaxDoublings: rc.MaxDoublings,
+	}
+	if rc.MaxRetryDuration != nil {
+		pb.MaxRetryDuration = ptypes.DurationProto(rc.MaxRetryDuration.Duration)
+	}
+	if rc.MinBackoffDuration != nil {
+		pb.MinBackoffDuration = ptypes.DurationProto(rc.MinBackoffDuration.Duration)
+	}
+	if rc.MaxBackoffDuration != nil {
+		pb.MaxBackoffDuration = ptypes.DurationProto(rc.MaxBackoffDuration.Duration)
+	}
+	return pb
+}
+
+// retryConfigChanged returns true if the existing and desired retry
+// configurations differ. Cloud Scheduler fills in defaults for the unset
+// fields, so those are filled in on both sides before comparing.
+func retryConfigChanged(existing, desired *schedulerpb.RetryConfig) bool {
+	return !proto.Equal(withRetryDefaults(existing), withRetryDefaults(desired))
+}
+
+func withRetryDefaults(rc *schedulerpb.RetryConfig) *schedulerpb.RetryConfig {
+	out := &schedulerpb.RetryConfig{}
+	if rc != nil {
+		out = proto.Clone(rc).(*schedulerpb.RetryConfig)
+	}
+	if out.MaxRetryDuration == nil {
+		out.MaxRetryDuration = ptypes.DurationProto(0)
+	}
+	if out.MinBackoffDuration == nil {
+		out.MinBackoffDuration = ptypes.DurationProto(5 * time.Second)
+	}
+	if out.MaxBackoffDuration == nil {
+		out.MaxBackoffDuration = ptypes.DurationProto(time.Hour)
+	}
+	if out.MaxDoublings == 0 {
+		out.MaxDoublings = 5
+	}
+	return out
+}
+
+func (c *Reconciler) deleteJob(csr *v1alpha1.CloudSchedulerSource) error {
+	jobName := c.jobName(csr)
+	jobBackend, err := c.backendFor(csr)
 	if err != nil {
+		// Don't keep the source around forever because of it.
+		c.Logger.Warnf("%s, leaving job %q behind", err, jobName)
+		return nil
+	}
+
+	ctx := context.Background()
+	c.Logger.Infof("Deleting job as: %q", jobName)
+	if err := deleteJobNamed(ctx, jobBackend, jobName); err != nil {
 		return err
 	}
+	c.Logger.Infof("Deleted job: %+v", jobName)
 
+	// Also delete the job we had before, in case it was never replaced.
+	return c.deletePreviousJob(ctx, jobBackend, csr, jobName)
+}
+
+// deleteJobNamed deletes the named job, if it exists.
+func deleteJobNamed(ctx context.Context, jobBackend backend.JobBackend, jobName string) error {
 	deleteReq := &schedulerpb.DeleteJobRequest{
 		Name: jobName,
 	}
-
-	c.Logger.Infof("Deleting job as: %q", jobName)
-	err = csc.DeleteJob(ctx, deleteReq)
-	if err == nil {
-		c.Logger.Infof("Deleted job: %+v", jobName)
+	err := jobBackend.DeleteJob(ctx, deleteReq)
+	if st, ok := gstatus.FromError(err); ok && st.Code() == codes.NotFound {
 		return nil
 	}
+	return err
+}
 
-	if st, ok := gstatus.FromError(err); !ok {
-		c.Logger.Infof("Unknown error from the cloud scheduler client: %s", err)
-		return err
-	} else if st.Code() != codes.NotFound {
-		return err
+// backendKind returns the kind of backend that runs the job of the given
+// source.
+func (c *Reconciler) backendKind(csr *v1alpha1.CloudSchedulerSource) v1alpha1.SchedulerBackend {
+	if csr.Spec.Backend != "" {
+		return csr.Spec.Backend
 	}
-	return nil
+	return c.defaultBackend
+}
+
+// backendFor returns the JobBackend that runs the job of the given source.
+func (c *Reconciler) backendFor(csr *v1alpha1.CloudSchedulerSource) (backend.JobBackend, error) {
+	kind := c.backendKind(csr)
+	jobBackend, ok := c.jobBackends[kind]
+	if !ok {
+		return nil, fmt.Errorf("scheduler backend %q is not available", kind)
+	}
+	return jobBackend, nil
+}
+
+// jobParent returns the parent resource to create the job of the given
+// source under.
+func (c *Reconciler) jobParent(csr *v1alpha1.CloudSchedulerSource) string {
+	if c.backendKind(csr) == v1alpha1.SchedulerBackendInCluster {
+		return fmt.Sprintf("namespaces/%s", csr.Namespace)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", csr.Spec.GoogleCloudProject, csr.Spec.Location)
+}
+
+// jobName returns the full name of the job of the given source.
+func (c *Reconciler) jobName(csr *v1alpha1.CloudSchedulerSource) string {
+	return fmt.Sprintf("%s/jobs/%s", c.jobParent(csr), resources.JobID(csr))
+}
+
+// eventAttributes returns the type and source attributes of the events of
+// the given source. The source attribute is the full resource name of the
+// Cloud Scheduler job, or the URI of the source for the backends that don't
+// run one, unless the spec overrides them.
+func (c *Reconciler) eventAttributes(csr *v1alpha1.CloudSchedulerSource) (string, string) {
+	eventType := receiveadapter.EventType
+	eventSource := fmt.Sprintf("//cloudscheduler.googleapis.com/%s", c.jobName(csr))
+	if c.backendKind(csr) != v1alpha1.SchedulerBackendCloudScheduler {
+		eventSource = fmt.Sprintf("/apis/%s/namespaces/%s/cloudschedulersources/%s",
+			v1alpha1.SchemeGroupVersion, csr.Namespace, csr.Name)
+	}
+	if ce := csr.Spec.CloudEvents; ce != nil {
+		if ce.Type != "" {
+			eventType = ce.Type
+		}
+		if ce.Source != "" {
+			eventSource = ce.Source
+		}
+	}
+	return eventType, eventSource
 }
 
 func (c *Reconciler) addFinalizer(csr *v1alpha1.CloudSchedulerSource) {
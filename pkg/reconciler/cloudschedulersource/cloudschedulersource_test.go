@@ -0,0 +1,1579 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudschedulersource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/go-cmp/cmp"
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	servingv1alpha1 "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"go.uber.org/zap"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+	"github.com/vaikas-google/csr/pkg/backend"
+	fakeclientset "github.com/vaikas-google/csr/pkg/client/clientset/versioned/fake"
+	listers "github.com/vaikas-google/csr/pkg/client/listers/cloudschedulersource/v1alpha1"
+	"github.com/vaikas-google/csr/pkg/receiveadapter"
+	"github.com/vaikas-google/csr/pkg/reconciler/cloudschedulersource/resources"
+	"github.com/vaikas-google/csr/pkg/tracker"
+)
+
+const (
+	testNS       = "testnamespace"
+	testName     = "testsource"
+	testProject  = "testproject"
+	testLocation = "us-central1"
+	testSchedule = "*/5 * * * *"
+	testImage    = "github.com/vaikas-google/csr/cmd/receiveadapter"
+
+	sinkName     = "testsink"
+	sinkHostname = "testsink.testnamespace.svc.cluster.local"
+	sinkURI      = "http://" + sinkHostname + "/"
+
+	deadLetterSinkName     = "testdeadletters"
+	deadLetterSinkHostname = "testdeadletters.testnamespace.svc.cluster.local"
+
+	serviceDomain = "testsource.testnamespace.example.com"
+	serviceURI    = "http://" + serviceDomain + "/"
+
+	testServiceAccount = "scheduler@" + testProject + ".iam.gserviceaccount.com"
+
+	testUID     = "test-uid"
+	testParent  = "projects/" + testProject + "/locations/" + testLocation
+	testJobName = testParent + "/jobs/" + testNS + "_" + testName + "_" + testUID
+	// The source attribute of the events of the job.
+	testEventSource = "//cloudscheduler.googleapis.com/" + testJobName
+	// The job name used by earlier versions of the controller.
+	legacyJobName = testParent + "/jobs/" + testName
+)
+
+var deletionTime = metav1.Now()
+
+// condition is the part of a condition the tests check.
+type condition struct {
+	status corev1.ConditionStatus
+	reason string
+}
+
+func TestReconcile(t *testing.T) {
+	testCases := []struct {
+		name string
+		// The source being reconciled.
+		source *v1alpha1.CloudSchedulerSource
+		// Existing objects.
+		sinks    []*unstructured.Unstructured
+		services []*servingv1alpha1.Service
+		jobs     []*schedulerpb.Job
+		// Errors to inject, keyed by method name.
+		serviceErrors map[string]error
+		backendErrors map[string]error
+
+		wantErr bool
+		// The conditions and other status the source should end up with.
+		wantConditions map[duckv1alpha1.ConditionType]condition
+		wantSinkURI    string
+		// The URI the dead letter sink resolved to, if the source has one.
+		wantDeadLetterSinkURI string
+		// The type and source attributes of the events, not checked if
+		// empty.
+		wantEventType   string
+		wantEventSource string
+		wantJob         string
+		// The state of the job in the status, not checked if empty.
+		wantJobState   string
+		wantFinalizers []string
+		// The jobs that should exist afterwards.
+		wantJobs []*schedulerpb.Job
+		// Whether the Receive Adapter Service should exist afterwards.
+		wantService bool
+		// The sink the Receive Adapter Service should send to, if it exists.
+		wantServiceSink string
+		// The beginnings of the Events that should be recorded.
+		wantEvents []string
+	}{{
+		name:   "creates service, waits for its domain",
+		source: source(),
+		sinks:  []*unstructured.Unstructured{addressableSink(sinkHostname)},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionFalse, "ServiceDomainMissing"},
+			v1alpha1.CloudSchedulerSourceConditionSinkProvided: {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionFalse, "ServiceDomainMissing"},
+			v1alpha1.CloudSchedulerSourceConditionJobReady:     {corev1.ConditionUnknown, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+		wantService:    true,
+	}, {
+		name:     "creates job",
+		source:   source(),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionSinkProvided: {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionJobReady:     {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantJobState:   "ENABLED",
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:    true,
+	}, {
+		name:     "updates job",
+		source:   source(),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job("0 * * * *", serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:    true,
+		wantEvents:     []string{"Normal JobUpdated Updated schedule of job"},
+	}, {
+		name: "expands schedule macros",
+		source: source(func(csr *v1alpha1.CloudSchedulerSource) {
+			csr.Spec.Schedule = "@daily"
+		}),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job("0 0 * * *", serviceURI)},
+		wantService:    true,
+	}, {
+		name:     "adds oidc token to job",
+		source:   source(withOIDCToken),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			withJobOIDCToken(job(testSchedule, serviceURI), testServiceAccount, serviceURI),
+		},
+		wantService: true,
+		wantEvents:  []string{"Normal JobUpdated Updated http_target of job"},
+	}, {
+		name:     "adds headers to job",
+		source:   source(withHeaders(map[string]string{"X-Team": "billing"})),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			withJobHeaders(job(testSchedule, serviceURI), map[string]string{"X-Team": "billing"}),
+		},
+		wantService: true,
+		wantEvents:  []string{"Normal JobUpdated Updated http_target of job"},
+	}, {
+		name:     "updates job retry config",
+		source:   source(withRetryConfig(&v1alpha1.RetryConfig{RetryCount: 3})),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			withJobRetryConfig(job(testSchedule, serviceURI), &schedulerpb.RetryConfig{RetryCount: 3}),
+		},
+		wantService: true,
+		wantEvents:  []string{"Normal JobUpdated Updated retry_config of job"},
+	}, {
+		name:   "corrects job edited outside of the source",
+		source: source(withFinalizer),
+		sinks:  []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		// The domain of the Receive Adapter Service changed.
+		services: []*servingv1alpha1.Service{service("testsource.testnamespace.example.org")},
+		jobs: []*schedulerpb.Job{
+			withJobDescription(withJobHeaders(job(testSchedule, serviceURI), map[string]string{"X-Edited": "true"}), "edited"),
+		},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job(testSchedule, "http://testsource.testnamespace.example.org/")},
+		wantService:    true,
+		wantEvents:     []string{"Normal JobUpdated Updated description, http_target of job"},
+	}, {
+		name:     "headers set by Cloud Scheduler are up to date",
+		source:   source(withFinalizer),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs: []*schedulerpb.Job{
+			withJobHeaders(job(testSchedule, serviceURI), map[string]string{"User-Agent": "Google-Cloud-Scheduler"}),
+		},
+		backendErrors: map[string]error{"UpdateJob": gstatus.Error(codes.Internal, "job should not be updated")},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			withJobHeaders(job(testSchedule, serviceURI), map[string]string{"User-Agent": "Google-Cloud-Scheduler"}),
+		},
+		wantService: true,
+	}, {
+		name:     "resumes job paused outside of the source",
+		source:   source(withFinalizer),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{withJobState(job(testSchedule, serviceURI), schedulerpb.Job_PAUSED)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{withJobState(job(testSchedule, serviceURI), schedulerpb.Job_ENABLED)},
+		wantService:    true,
+		wantEvents:     []string{"Normal JobResumed Resumed job"},
+	}, {
+		name:     "creates suspended job paused",
+		source:   source(withSuspend),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantJobState:   "PAUSED",
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{withJobState(job(testSchedule, serviceURI), schedulerpb.Job_PAUSED)},
+		wantService:    true,
+		wantEvents:     []string{"Normal JobPaused Paused job"},
+	}, {
+		name:     "pauses job when suspended",
+		source:   source(withFinalizer, withSuspend),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantJobState:   "PAUSED",
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{withJobState(job(testSchedule, serviceURI), schedulerpb.Job_PAUSED)},
+		wantService:    true,
+		wantEvents:     []string{"Normal JobPaused Paused job"},
+	}, {
+		name:     "suspended job up to date",
+		source:   source(withFinalizer, withSuspend),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{withJobState(job(testSchedule, serviceURI), schedulerpb.Job_PAUSED)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantJobState:   "PAUSED",
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{withJobState(job(testSchedule, serviceURI), schedulerpb.Job_PAUSED)},
+		wantService:    true,
+	}, {
+		name:     "retry config defaulted by Cloud Scheduler is up to date",
+		source:   source(withFinalizer),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs: []*schedulerpb.Job{
+			withJobRetryConfig(job(testSchedule, serviceURI), &schedulerpb.RetryConfig{
+				MaxRetryDuration:   ptypes.DurationProto(0),
+				MinBackoffDuration: ptypes.DurationProto(5 * time.Second),
+				MaxBackoffDuration: ptypes.DurationProto(time.Hour),
+				MaxDoublings:       5,
+			}),
+		},
+		backendErrors: map[string]error{"UpdateJob": gstatus.Error(codes.Internal, "job should not be updated")},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			withJobRetryConfig(job(testSchedule, serviceURI), &schedulerpb.RetryConfig{
+				MaxRetryDuration:   ptypes.DurationProto(0),
+				MinBackoffDuration: ptypes.DurationProto(5 * time.Second),
+				MaxBackoffDuration: ptypes.DurationProto(time.Hour),
+				MaxDoublings:       5,
+			}),
+		},
+		wantService: true,
+	}, {
+		name:     "job up to date",
+		source:   source(withFinalizer),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		// Updating the job would fail, so this checks it isn't.
+		backendErrors: map[string]error{"UpdateJob": fmt.Errorf("update not expected")},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:    true,
+	}, {
+		name:     "migrates legacy job",
+		source:   source(withFinalizer, withStatusJob(legacyJobName)),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{namedJob(legacyJobName, testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:    true,
+	}, {
+		name:     "leaves job with the same name from another namespace alone",
+		source:   source(),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{namedJob(legacyJobName, "0 * * * *", "http://other/")},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:    sinkURI,
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			job(testSchedule, serviceURI),
+			namedJob(legacyJobName, "0 * * * *", "http://other/"),
+		},
+		wantService: true,
+	}, {
+		name:     "updates service when the sink moves",
+		source:   source(withFinalizer),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{serviceWithSink(serviceDomain, "http://oldsink/")},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:     sinkURI,
+		wantJob:         testJobName,
+		wantFinalizers:  []string{finalizerName},
+		wantJobs:        []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:     true,
+		wantServiceSink: sinkURI,
+	}, {
+		name:          "service up to date",
+		source:        source(withFinalizer),
+		sinks:         []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services:      []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:          []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		serviceErrors: map[string]error{"Update": fmt.Errorf("service should not be updated")},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:     sinkURI,
+		wantJob:         testJobName,
+		wantFinalizers:  []string{finalizerName},
+		wantJobs:        []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:     true,
+		wantServiceSink: sinkURI,
+	}, {
+		name:     "event attributes from the spec",
+		source:   source(withFinalizer, withEventAttributes("com.example.billing.nightly", "/billing/nightly")),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:     sinkURI,
+		wantEventType:   "com.example.billing.nightly",
+		wantEventSource: "/billing/nightly",
+		wantJob:         testJobName,
+		wantFinalizers:  []string{finalizerName},
+		wantJobs:        []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:     true,
+	}, {
+		name:          "updating service fails",
+		source:        source(withFinalizer),
+		sinks:         []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services:      []*servingv1alpha1.Service{serviceWithSink(serviceDomain, "http://oldsink/")},
+		serviceErrors: map[string]error{"Update": fmt.Errorf("inducing failure for update services")},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionFalse, "ServiceReconcileFailed"},
+		},
+		wantSinkURI:     sinkURI,
+		wantFinalizers:  []string{finalizerName},
+		wantService:     true,
+		wantServiceSink: "http://oldsink/",
+	}, {
+		name:   "resolves dead letter sink",
+		source: source(withFinalizer, withDeadLetterSink),
+		sinks: []*unstructured.Unstructured{
+			addressableSink(sinkHostname),
+			namedAddressableSink(deadLetterSinkName, deadLetterSinkHostname),
+		},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:     []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantSinkURI:           sinkURI,
+		wantDeadLetterSinkURI: "http://" + deadLetterSinkHostname + "/",
+		wantJob:               testJobName,
+		wantFinalizers:        []string{finalizerName},
+		wantJobs:              []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		wantService:           true,
+	}, {
+		name:     "dead letter sink missing",
+		source:   source(withFinalizer, withDeadLetterSink),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:                  {corev1.ConditionFalse, "NotFound"},
+			v1alpha1.CloudSchedulerSourceConditionSinkProvided:           {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionDeadLetterSinkProvided: {corev1.ConditionFalse, "NotFound"},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+		wantService:    true,
+	}, {
+		name:   "sink missing",
+		source: source(),
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionFalse, "NotFound"},
+			v1alpha1.CloudSchedulerSourceConditionSinkProvided: {corev1.ConditionFalse, "NotFound"},
+		},
+	}, {
+		name:   "sink not addressable",
+		source: source(),
+		sinks:  []*unstructured.Unstructured{addressableSink("")},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionSinkProvided: {corev1.ConditionFalse, "NotFound"},
+		},
+	}, {
+		name:          "service creation fails",
+		source:        source(),
+		sinks:         []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		serviceErrors: map[string]error{"Create": fmt.Errorf("inducing failure for create services")},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionFalse, "ServiceReconcileFailed"},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionFalse, "ServiceReconcileFailed"},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+	}, {
+		name:          "getting job fails",
+		source:        source(),
+		sinks:         []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services:      []*servingv1alpha1.Service{service(serviceDomain)},
+		backendErrors: map[string]error{"GetJob": gstatus.Error(codes.PermissionDenied, "inducing failure for get job")},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionFalse, "JobReconcileFailed"},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionJobReady:     {corev1.ConditionFalse, "JobReconcileFailed"},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+		wantService:    true,
+	}, {
+		name:          "creating job fails",
+		source:        source(),
+		sinks:         []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services:      []*servingv1alpha1.Service{service(serviceDomain)},
+		backendErrors: map[string]error{"CreateJob": gstatus.Error(codes.Internal, "inducing failure for create job")},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "JobReconcileFailed"},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+		wantService:    true,
+	}, {
+		name:          "updating job fails",
+		source:        source(),
+		sinks:         []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services:      []*servingv1alpha1.Service{service(serviceDomain)},
+		jobs:          []*schedulerpb.Job{job("0 * * * *", serviceURI)},
+		backendErrors: map[string]error{"UpdateJob": gstatus.Error(codes.Internal, "inducing failure for update job")},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "JobReconcileFailed"},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job("0 * * * *", serviceURI)},
+		wantService:    true,
+	}, {
+		name:     "backend not available",
+		source:   source(withBackend(v1alpha1.SchedulerBackendInCluster)),
+		sinks:    []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		services: []*servingv1alpha1.Service{service(serviceDomain)},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "JobReconcileFailed"},
+		},
+		wantSinkURI:    sinkURI,
+		wantFinalizers: []string{finalizerName},
+		wantService:    true,
+	}, {
+		name:   "deleted, backend not available",
+		source: source(withFinalizer, withDeletionTimestamp, withBackend(v1alpha1.SchedulerBackendInCluster)),
+		sinks:  []*unstructured.Unstructured{addressableSink(sinkHostname)},
+
+		wantSinkURI: sinkURI,
+	}, {
+		name:   "creates app engine job without service",
+		source: source(withAppEngineTarget),
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionSinkProvided: {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{appEngineJob()},
+	}, {
+		name:   "app engine job with routing host filled in is up to date",
+		source: source(withFinalizer, withAppEngineTarget),
+		jobs: []*schedulerpb.Job{
+			withAppEngineHost(appEngineJob(), "v2.worker.testproject.appspot.com"),
+		},
+		backendErrors: map[string]error{"UpdateJob": gstatus.Error(codes.Internal, "job should not be updated")},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs: []*schedulerpb.Job{
+			withAppEngineHost(appEngineJob(), "v2.worker.testproject.appspot.com"),
+		},
+	}, {
+		name:   "updates app engine routing",
+		source: source(withFinalizer, withAppEngineTarget),
+		jobs: []*schedulerpb.Job{func() *schedulerpb.Job {
+			j := appEngineJob()
+			j.GetAppEngineHttpTarget().AppEngineRouting.Version = "v1"
+			return j
+		}()},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:        testJobName,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{appEngineJob()},
+		wantEvents:     []string{"Normal JobUpdated Updated app_engine_http_target of job"},
+	}, {
+		name:   "deleted, deletes job and removes finalizer",
+		source: source(withFinalizer, withDeletionTimestamp),
+		sinks:  []*unstructured.Unstructured{addressableSink(sinkHostname)},
+		jobs:   []*schedulerpb.Job{job(testSchedule, serviceURI)},
+
+		wantSinkURI: sinkURI,
+	}, {
+		name:   "deleted, deletes legacy job",
+		source: source(withFinalizer, withDeletionTimestamp, withStatusJob(legacyJobName)),
+		jobs:   []*schedulerpb.Job{namedJob(legacyJobName, testSchedule, serviceURI)},
+
+		wantJob: legacyJobName,
+	}, {
+		name:   "deleted without sink, job already gone",
+		source: source(withFinalizer, withDeletionTimestamp),
+	}, {
+		name:          "deleted, deleting job fails",
+		source:        source(withFinalizer, withDeletionTimestamp),
+		jobs:          []*schedulerpb.Job{job(testSchedule, serviceURI)},
+		backendErrors: map[string]error{"DeleteJob": gstatus.Error(codes.Internal, "inducing failure for delete job")},
+
+		wantErr:        true,
+		wantFinalizers: []string{finalizerName},
+		wantJobs:       []*schedulerpb.Job{job(testSchedule, serviceURI)},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fakeclientset.NewSimpleClientset(tc.source)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if err := indexer.Add(tc.source); err != nil {
+				t.Fatalf("Failed to seed lister: %v", err)
+			}
+			servingClient := newFakeServingClient(tc.serviceErrors, tc.services...)
+			jobBackend := newFakeBackend(tc.backendErrors, tc.jobs...)
+			recorder := record.NewFakeRecorder(10)
+
+			r := &Reconciler{
+				cloudschedulersourceclientset: client,
+				cloudschedulersourcesLister:   listers.NewCloudSchedulerSourceLister(indexer),
+				dynamicClient:                 &fakeDynamicClient{objects: tc.sinks},
+				sinkInformerFactory:           &fakeInformerFactory{},
+				tracker:                       tracker.New(func(string) {}, time.Minute),
+				servingClient:                 servingClient,
+				jobBackends: map[v1alpha1.SchedulerBackend]backend.JobBackend{
+					v1alpha1.SchedulerBackendCloudScheduler: jobBackend,
+				},
+				defaultBackend: v1alpha1.SchedulerBackendCloudScheduler,
+				raImage:        testImage,
+				recorder:       recorder,
+				Logger:         zap.NewNop().Sugar(),
+			}
+
+			err := r.Reconcile(context.Background(), testNS+"/"+testName)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Reconcile() = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			got, err := client.SourcesV1alpha1().CloudSchedulerSources(testNS).Get(testName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get source: %v", err)
+			}
+			for ct, want := range tc.wantConditions {
+				c := got.Status.GetCondition(ct)
+				if c == nil {
+					t.Errorf("Condition %q missing", ct)
+					continue
+				}
+				if c.Status != want.status || c.Reason != want.reason {
+					t.Errorf("Condition %q = (%s, %q), want (%s, %q)", ct, c.Status, c.Reason, want.status, want.reason)
+				}
+			}
+			if got.Status.SinkURI != tc.wantSinkURI {
+				t.Errorf("SinkURI = %q, want %q", got.Status.SinkURI, tc.wantSinkURI)
+			}
+			if got.Status.DeadLetterSinkURI != tc.wantDeadLetterSinkURI {
+				t.Errorf("DeadLetterSinkURI = %q, want %q", got.Status.DeadLetterSinkURI, tc.wantDeadLetterSinkURI)
+			}
+			if tc.wantEventType != "" && got.Status.EventType != tc.wantEventType {
+				t.Errorf("EventType = %q, want %q", got.Status.EventType, tc.wantEventType)
+			}
+			if tc.wantEventSource != "" && got.Status.EventSource != tc.wantEventSource {
+				t.Errorf("EventSource = %q, want %q", got.Status.EventSource, tc.wantEventSource)
+			}
+			if got.Status.Job != tc.wantJob {
+				t.Errorf("Job = %q, want %q", got.Status.Job, tc.wantJob)
+			}
+			if tc.wantJobState != "" && got.Status.JobState != tc.wantJobState {
+				t.Errorf("JobState = %q, want %q", got.Status.JobState, tc.wantJobState)
+			}
+			if !equalStrings(got.Finalizers, tc.wantFinalizers) {
+				t.Errorf("Finalizers = %v, want %v", got.Finalizers, tc.wantFinalizers)
+			}
+
+			gotJobs := jobBackend.Jobs()
+			if len(gotJobs) != len(tc.wantJobs) {
+				t.Fatalf("Got %d jobs, want %d: %v", len(gotJobs), len(tc.wantJobs), gotJobs)
+			}
+			for i, want := range tc.wantJobs {
+				g := gotJobs[i]
+				if g.Name != want.Name || g.Description != want.Description ||
+					g.Schedule != want.Schedule || g.TimeZone != want.TimeZone ||
+					!proto.Equal(g.GetHttpTarget(), want.GetHttpTarget()) ||
+					!proto.Equal(g.GetAppEngineHttpTarget(), want.GetAppEngineHttpTarget()) ||
+					!proto.Equal(g.RetryConfig, want.RetryConfig) ||
+					(want.State != schedulerpb.Job_STATE_UNSPECIFIED && g.State != want.State) {
+					t.Errorf("Job %d = %v, want %v", i, g, want)
+				}
+			}
+
+			close(recorder.Events)
+			var gotEvents []string
+			for e := range recorder.Events {
+				gotEvents = append(gotEvents, e)
+			}
+			if len(gotEvents) != len(tc.wantEvents) {
+				t.Errorf("Events = %q, want %q", gotEvents, tc.wantEvents)
+			} else {
+				for i, want := range tc.wantEvents {
+					if !strings.HasPrefix(gotEvents[i], want) {
+						t.Errorf("Event %d = %q, want it to start with %q", i, gotEvents[i], want)
+					}
+				}
+			}
+
+			svc, err := servingClient.ServingV1alpha1().Services(testNS).Get(testName, metav1.GetOptions{})
+			if tc.wantService {
+				if err != nil {
+					t.Errorf("Failed to get service: %v", err)
+				} else if !metav1.IsControlledBy(svc, got) {
+					t.Errorf("Service is not controlled by the source: %v", svc.OwnerReferences)
+				} else if tc.wantServiceSink != "" {
+					args := svc.Spec.RunLatest.Configuration.RevisionTemplate.Spec.Container.Args
+					want := []string{
+						"--sink=" + tc.wantServiceSink,
+						"--event-type=" + receiveadapter.EventType,
+						"--event-source=" + testEventSource,
+					}
+					if !equalStrings(args, want) {
+						t.Errorf("Service args = %v, want %v", args, want)
+					}
+				}
+			} else if err == nil {
+				t.Errorf("Unexpected service: %v", svc)
+			}
+		})
+	}
+}
+
+func TestReconcilePubSubTarget(t *testing.T) {
+	const (
+		testTopic        = "projects/" + testProject + "/topics/cloudschedulersource-" + testUID
+		testSubscription = "projects/" + testProject + "/subscriptions/cloudschedulersource-" + testUID
+	)
+	pubsubSource := func(opts ...sourceOption) *v1alpha1.CloudSchedulerSource {
+		csr := source(opts...)
+		csr.Spec.Body = "hello"
+		csr.Spec.PubSubTarget = &v1alpha1.PubSubTarget{Attributes: map[string]string{"team": "billing"}}
+		return csr
+	}
+	availableDeployment := func() *appsv1.Deployment {
+		csr := pubsubSource()
+		csr.SetDefaults()
+		csr.Status.SinkURI = sinkURI
+		csr.Status.EventType = receiveadapter.EventType
+		csr.Status.EventSource = testEventSource
+		d := resources.MakeDeployment(csr, testImage, testSubscription)
+		d.Status.AvailableReplicas = 1
+		return d
+	}
+
+	testCases := []struct {
+		name        string
+		source      *v1alpha1.CloudSchedulerSource
+		deployments []*appsv1.Deployment
+		existing    bool
+		noPubSub    bool
+
+		wantConditions map[duckv1alpha1.ConditionType]condition
+		wantTopic      string
+		wantJobs       int
+		wantDeployment bool
+		wantFinalizers []string
+	}{{
+		name:   "creates topic, deployment and job",
+		source: pubsubSource(),
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionFalse, "DeploymentUnavailable"},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionFalse, "DeploymentUnavailable"},
+			v1alpha1.CloudSchedulerSourceConditionJobReady:     {corev1.ConditionTrue, ""},
+		},
+		wantTopic:      testTopic,
+		wantJobs:       1,
+		wantDeployment: true,
+		wantFinalizers: []string{finalizerName},
+	}, {
+		name:        "ready once the deployment is available",
+		source:      pubsubSource(withFinalizer),
+		deployments: []*appsv1.Deployment{availableDeployment()},
+		existing:    true,
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantTopic:      testTopic,
+		wantJobs:       1,
+		wantDeployment: true,
+		wantFinalizers: []string{finalizerName},
+	}, {
+		name:     "deleted, deletes topic",
+		source:   pubsubSource(withFinalizer, withDeletionTimestamp),
+		existing: true,
+	}, {
+		name:     "deleted, Pub/Sub unavailable",
+		source:   pubsubSource(withFinalizer, withDeletionTimestamp),
+		noPubSub: true,
+	}, {
+		name:   "not supported by the InCluster backend",
+		source: pubsubSource(withBackend(v1alpha1.SchedulerBackendInCluster)),
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "TopicReconcileFailed"},
+		},
+		wantFinalizers: []string{finalizerName},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fakeclientset.NewSimpleClientset(tc.source)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err := indexer.Add(tc.source); err != nil {
+				t.Fatalf("Failed to seed lister: %v", err)
+			}
+			pubSub := newFakePubSub()
+			var jobs []*schedulerpb.Job
+			if tc.existing {
+				pubSub.topics[testTopic] = &pubsubpb.Topic{Name: testTopic}
+				pubSub.subscriptions[testSubscription] = &pubsubpb.Subscription{Name: testSubscription, Topic: testTopic}
+				jobs = append(jobs, &schedulerpb.Job{
+					Name:     testJobName,
+					Schedule: testSchedule,
+					Target: &schedulerpb.Job_PubsubTarget{
+						PubsubTarget: &schedulerpb.PubsubTarget{TopicName: testTopic},
+					},
+				})
+			}
+			jobBackend := newFakeBackend(nil, jobs...)
+			kubeClient := newFakeKubeClient(tc.deployments...)
+
+			r := &Reconciler{
+				kubeclientset:                 kubeClient,
+				cloudschedulersourceclientset: client,
+				cloudschedulersourcesLister:   listers.NewCloudSchedulerSourceLister(indexer),
+				dynamicClient:                 &fakeDynamicClient{objects: []*unstructured.Unstructured{addressableSink(sinkHostname)}},
+				sinkInformerFactory:           &fakeInformerFactory{},
+				tracker:                       tracker.New(func(string) {}, time.Minute),
+				jobBackends: map[v1alpha1.SchedulerBackend]backend.JobBackend{
+					v1alpha1.SchedulerBackendCloudScheduler: jobBackend,
+				},
+				defaultBackend: v1alpha1.SchedulerBackendCloudScheduler,
+				raImage:        testImage,
+				recorder:       record.NewFakeRecorder(10),
+				Logger:         zap.NewNop().Sugar(),
+			}
+			if !tc.noPubSub {
+				r.pubSub = pubSub
+			}
+
+			r.Reconcile(context.Background(), testNS+"/"+testName)
+
+			got, err := client.SourcesV1alpha1().CloudSchedulerSources(testNS).Get(testName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get source: %v", err)
+			}
+			for ct, want := range tc.wantConditions {
+				c := got.Status.GetCondition(ct)
+				if c == nil || c.Status != want.status || c.Reason != want.reason {
+					t.Errorf("Condition %q = %+v, want (%s, %q)", ct, c, want.status, want.reason)
+				}
+			}
+			if got.Status.Topic != tc.wantTopic {
+				t.Errorf("Topic = %q, want %q", got.Status.Topic, tc.wantTopic)
+			}
+			if !equalStrings(got.Finalizers, tc.wantFinalizers) {
+				t.Errorf("Finalizers = %v, want %v", got.Finalizers, tc.wantFinalizers)
+			}
+			if _, ok := pubSub.topics[testTopic]; ok != (tc.wantTopic != "") {
+				t.Errorf("Topic exists = %v, want %v", ok, tc.wantTopic != "")
+			}
+			if _, ok := pubSub.subscriptions[testSubscription]; ok != (tc.wantTopic != "") {
+				t.Errorf("Subscription exists = %v, want %v", ok, tc.wantTopic != "")
+			}
+
+			gotJobs := jobBackend.Jobs()
+			if len(gotJobs) != tc.wantJobs {
+				t.Fatalf("Got %d jobs, want %d: %v", len(gotJobs), tc.wantJobs, gotJobs)
+			}
+			if tc.wantJobs > 0 {
+				want := &schedulerpb.PubsubTarget{
+					TopicName:  testTopic,
+					Data:       []byte("hello"),
+					Attributes: map[string]string{"team": "billing"},
+				}
+				if g := gotJobs[0].GetPubsubTarget(); !proto.Equal(g, want) {
+					t.Errorf("Job target = %v, want %v", g, want)
+				}
+			}
+
+			d, err := kubeClient.AppsV1().Deployments(testNS).Get(testName, metav1.GetOptions{})
+			if tc.wantDeployment {
+				if err != nil {
+					t.Fatalf("Failed to get deployment: %v", err)
+				}
+				args := d.Spec.Template.Spec.Containers[0].Args
+				want := []string{
+					"--sink=" + sinkURI,
+					"--subscription=" + testSubscription,
+					"--job-name=" + testNS + "_" + testName + "_" + testUID,
+					"--event-type=" + receiveadapter.EventType,
+					"--event-source=" + testEventSource,
+				}
+				if !equalStrings(args, want) {
+					t.Errorf("Deployment args = %v, want %v", args, want)
+				}
+			} else if err == nil {
+				t.Errorf("Unexpected deployment: %v", d)
+			}
+		})
+	}
+}
+
+func TestReconcileCronJob(t *testing.T) {
+	cronJobSource := func(opts ...sourceOption) *v1alpha1.CloudSchedulerSource {
+		return source(append([]sourceOption{withBackend(v1alpha1.SchedulerBackendCronJob)}, opts...)...)
+	}
+	// cronJob returns the CronJob of a source with the given options, as
+	// changed by the given function.
+	cronJob := func(change func(*batchv1beta1.CronJob), opts ...sourceOption) *batchv1beta1.CronJob {
+		csr := cronJobSource(opts...)
+		csr.SetDefaults()
+		csr.Status.SinkURI = sinkURI
+		cj := resources.MakeCronJob(csr, testImage)
+		if change != nil {
+			change(cj)
+		}
+		return cj
+	}
+	testCronJobName := resources.CronJobName(source())
+
+	testCases := []struct {
+		name     string
+		source   *v1alpha1.CloudSchedulerSource
+		cronJobs []*batchv1beta1.CronJob
+
+		wantErr        bool
+		wantConditions map[duckv1alpha1.ConditionType]condition
+		wantJob        string
+		wantJobState   string
+		// The fields of the CronJob that should exist afterwards, if any.
+		wantSchedule string
+		wantImage    string
+		wantSuspend  bool
+	}{{
+		name:   "creates cronjob",
+		source: cronJobSource(),
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:        {corev1.ConditionTrue, ""},
+			v1alpha1.CloudSchedulerSourceConditionServiceReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:      "namespaces/" + testNS + "/cronjobs/" + testCronJobName,
+		wantJobState: "ENABLED",
+		wantSchedule: testSchedule,
+		wantImage:    testImage,
+	}, {
+		name:   "updates schedule",
+		source: cronJobSource(withFinalizer),
+		cronJobs: []*batchv1beta1.CronJob{cronJob(func(cj *batchv1beta1.CronJob) {
+			cj.Spec.Schedule = "0 * * * *"
+		})},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:      "namespaces/" + testNS + "/cronjobs/" + testCronJobName,
+		wantJobState: "ENABLED",
+		wantSchedule: testSchedule,
+		wantImage:    testImage,
+	}, {
+		name:   "updates image",
+		source: cronJobSource(withFinalizer),
+		cronJobs: []*batchv1beta1.CronJob{cronJob(func(cj *batchv1beta1.CronJob) {
+			cj.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image = "old-image"
+		})},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:      "namespaces/" + testNS + "/cronjobs/" + testCronJobName,
+		wantJobState: "ENABLED",
+		wantSchedule: testSchedule,
+		wantImage:    testImage,
+	}, {
+		name:     "suspends cronjob",
+		source:   cronJobSource(withFinalizer, withSuspend),
+		cronJobs: []*batchv1beta1.CronJob{cronJob(nil)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:      "namespaces/" + testNS + "/cronjobs/" + testCronJobName,
+		wantJobState: "PAUSED",
+		wantSchedule: testSchedule,
+		wantImage:    testImage,
+		wantSuspend:  true,
+	}, {
+		name:     "resumes cronjob",
+		source:   cronJobSource(withFinalizer),
+		cronJobs: []*batchv1beta1.CronJob{cronJob(nil, withSuspend)},
+
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady: {corev1.ConditionTrue, ""},
+		},
+		wantJob:      "namespaces/" + testNS + "/cronjobs/" + testCronJobName,
+		wantJobState: "ENABLED",
+		wantSchedule: testSchedule,
+		wantImage:    testImage,
+	}, {
+		name:   "cronjob not owned by the source",
+		source: cronJobSource(withFinalizer),
+		cronJobs: []*batchv1beta1.CronJob{cronJob(func(cj *batchv1beta1.CronJob) {
+			cj.OwnerReferences = nil
+			cj.Spec.Schedule = "0 * * * *"
+		})},
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionReady:    {corev1.ConditionFalse, "CronJobReconcileFailed"},
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "CronJobReconcileFailed"},
+		},
+		wantSchedule: "0 * * * *",
+		wantImage:    testImage,
+	}, {
+		name: "time zone not UTC",
+		source: cronJobSource(func(csr *v1alpha1.CloudSchedulerSource) {
+			csr.Spec.TimeZone = "America/New_York"
+		}),
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "CronJobReconcileFailed"},
+		},
+	}, {
+		name: "interval schedule",
+		source: cronJobSource(func(csr *v1alpha1.CloudSchedulerSource) {
+			csr.Spec.Schedule = "every 5 mins"
+		}),
+
+		wantErr: true,
+		wantConditions: map[duckv1alpha1.ConditionType]condition{
+			v1alpha1.CloudSchedulerSourceConditionJobReady: {corev1.ConditionFalse, "CronJobReconcileFailed"},
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fakeclientset.NewSimpleClientset(tc.source)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err := indexer.Add(tc.source); err != nil {
+				t.Fatalf("Failed to seed lister: %v", err)
+			}
+			kubeClient := newFakeKubeClient()
+			for _, cj := range tc.cronJobs {
+				kubeClient.cronJobs[cj.Namespace+"/"+cj.Name] = cj
+			}
+
+			r := &Reconciler{
+				kubeclientset:                 kubeClient,
+				cloudschedulersourceclientset: client,
+				cloudschedulersourcesLister:   listers.NewCloudSchedulerSourceLister(indexer),
+				dynamicClient:                 &fakeDynamicClient{objects: []*unstructured.Unstructured{addressableSink(sinkHostname)}},
+				sinkInformerFactory:           &fakeInformerFactory{},
+				tracker:                       tracker.New(func(string) {}, time.Minute),
+				jobBackends: map[v1alpha1.SchedulerBackend]backend.JobBackend{
+					v1alpha1.SchedulerBackendCloudScheduler: newFakeBackend(nil),
+				},
+				defaultBackend: v1alpha1.SchedulerBackendCloudScheduler,
+				raImage:        testImage,
+				recorder:       record.NewFakeRecorder(10),
+				Logger:         zap.NewNop().Sugar(),
+			}
+
+			err := r.Reconcile(context.Background(), testNS+"/"+testName)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Reconcile() = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			got, err := client.SourcesV1alpha1().CloudSchedulerSources(testNS).Get(testName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get source: %v", err)
+			}
+			for ct, want := range tc.wantConditions {
+				c := got.Status.GetCondition(ct)
+				if c == nil || c.Status != want.status || c.Reason != want.reason {
+					t.Errorf("Condition %q = %+v, want (%s, %q)", ct, c, want.status, want.reason)
+				}
+			}
+			if got.Status.Job != tc.wantJob {
+				t.Errorf("Job = %q, want %q", got.Status.Job, tc.wantJob)
+			}
+			if got.Status.JobState != tc.wantJobState {
+				t.Errorf("JobState = %q, want %q", got.Status.JobState, tc.wantJobState)
+			}
+
+			cj, err := kubeClient.BatchV1beta1().CronJobs(testNS).Get(testCronJobName, metav1.GetOptions{})
+			if tc.wantSchedule == "" {
+				if err == nil {
+					t.Errorf("Unexpected cronjob: %v", cj)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to get cronjob: %v", err)
+			}
+			if cj.Spec.Schedule != tc.wantSchedule {
+				t.Errorf("Schedule = %q, want %q", cj.Spec.Schedule, tc.wantSchedule)
+			}
+			if image := cj.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image; image != tc.wantImage {
+				t.Errorf("Image = %q, want %q", image, tc.wantImage)
+			}
+			if cronJobSuspended(cj) != tc.wantSuspend {
+				t.Errorf("Suspend = %v, want %v", cj.Spec.Suspend, tc.wantSuspend)
+			}
+		})
+	}
+}
+
+func TestReconcileTracksSink(t *testing.T) {
+	csr := source()
+	client := fakeclientset.NewSimpleClientset(csr)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(csr); err != nil {
+		t.Fatalf("Failed to seed lister: %v", err)
+	}
+	informers := &fakeInformerFactory{}
+	var enqueued []string
+	r := &Reconciler{
+		cloudschedulersourceclientset: client,
+		cloudschedulersourcesLister:   listers.NewCloudSchedulerSourceLister(indexer),
+		// The sink has no address yet.
+		dynamicClient:       &fakeDynamicClient{objects: []*unstructured.Unstructured{addressableSink("")}},
+		sinkInformerFactory: informers,
+		tracker: tracker.New(func(key string) {
+			enqueued = append(enqueued, key)
+		}, time.Minute),
+		Logger: zap.NewNop().Sugar(),
+	}
+
+	if err := r.Reconcile(context.Background(), testNS+"/"+testName); err == nil {
+		t.Fatal("Reconcile() = nil, want an error for the sink without an address")
+	}
+	wantResource := schema.GroupVersionResource{Group: "eventing.knative.dev", Version: "v1alpha1", Resource: "channels"}
+	if len(informers.resources) != 1 || informers.resources[0] != wantResource {
+		t.Errorf("Informers started for %v, want %v", informers.resources, wantResource)
+	}
+
+	// The sink getting an address should enqueue the source.
+	r.tracker.OnChanged(addressableSink(sinkHostname))
+	if want := []string{testNS + "/" + testName}; !equalStrings(enqueued, want) {
+		t.Errorf("Enqueued %v, want %v", enqueued, want)
+	}
+
+	// But not a sink of the same name in another namespace.
+	enqueued = nil
+	other := addressableSink(sinkHostname)
+	other.SetNamespace("other")
+	r.tracker.OnChanged(other)
+	if len(enqueued) != 0 {
+		t.Errorf("Enqueued %v, want nothing", enqueued)
+	}
+}
+
+func TestReconcileTracksMissingSink(t *testing.T) {
+	channels := schema.GroupVersionResource{Group: "eventing.knative.dev", Version: "v1alpha1", Resource: "channels"}
+	tests := []struct {
+		name    string
+		unknown []schema.GroupVersionResource
+		// wantInformers are the resources informers are started for.
+		wantInformers []schema.GroupVersionResource
+	}{{
+		name:          "sink not created yet",
+		wantInformers: []schema.GroupVersionResource{channels},
+	}, {
+		// The informer would never sync.
+		name:    "unknown sink kind",
+		unknown: []schema.GroupVersionResource{channels},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			csr := source()
+			client := fakeclientset.NewSimpleClientset(csr)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err := indexer.Add(csr); err != nil {
+				t.Fatalf("Failed to seed lister: %v", err)
+			}
+			informers := &fakeInformerFactory{}
+			var enqueued []string
+			r := &Reconciler{
+				cloudschedulersourceclientset: client,
+				cloudschedulersourcesLister:   listers.NewCloudSchedulerSourceLister(indexer),
+				dynamicClient:                 &fakeDynamicClient{unknown: tc.unknown},
+				sinkInformerFactory:           informers,
+				tracker:                       tracker.New(func(key string) { enqueued = append(enqueued, key) }, time.Minute),
+				Logger:                        zap.NewNop().Sugar(),
+			}
+
+			if err := r.Reconcile(context.Background(), testNS+"/"+testName); err == nil {
+				t.Fatal("Reconcile() = nil, want an error for the missing sink")
+			}
+			if diff := cmp.Diff(tc.wantInformers, informers.resources); diff != "" {
+				t.Errorf("Informers started (-want +got): %s", diff)
+			}
+			// Either way the source is reconciled once the sink shows up.
+			r.tracker.OnChanged(addressableSink(sinkHostname))
+			if want := []string{testNS + "/" + testName}; !cmp.Equal(enqueued, want) {
+				t.Errorf("Enqueued %v once the sink was created, want %v", enqueued, want)
+			}
+		})
+	}
+}
+
+func TestIsUnknownResource(t *testing.T) {
+	channels := schema.GroupResource{Group: "eventing.knative.dev", Resource: "channels"}
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{{
+		name: "no error",
+	}, {
+		name: "object not found",
+		err:  errors.NewNotFound(channels, sinkName),
+	}, {
+		name: "forbidden",
+		err:  errors.NewForbidden(channels, sinkName, fmt.Errorf("no")),
+	}, {
+		name: "other error",
+		err:  fmt.Errorf("sink does not contain address"),
+	}, {
+		name: "no kind match",
+		err:  &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "eventing.knative.dev", Kind: "Channel"}},
+		want: true,
+	}, {
+		name: "404 page",
+		err:  errors.NewGenericServerResponse(http.StatusNotFound, "get", channels, sinkName, "404 page not found", 0, true),
+		want: true,
+	}, {
+		name: "404 status",
+		err: &errors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusNotFound,
+			Reason:  metav1.StatusReasonNotFound,
+			Message: "the server could not find the requested resource",
+		}},
+		want: true,
+	}} {
+		if got := isUnknownResource(tc.err); got != tc.want {
+			t.Errorf("isUnknownResource(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReconcileMissingSource(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	r := &Reconciler{
+		cloudschedulersourcesLister: listers.NewCloudSchedulerSourceLister(indexer),
+		Logger:                      zap.NewNop().Sugar(),
+	}
+	if err := r.Reconcile(context.Background(), testNS+"/"+testName); err != nil {
+		t.Errorf("Reconcile() = %v, want nil", err)
+	}
+}
+
+type sourceOption func(*v1alpha1.CloudSchedulerSource)
+
+func source(opts ...sourceOption) *v1alpha1.CloudSchedulerSource {
+	csr := &v1alpha1.CloudSchedulerSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testName,
+			Namespace: testNS,
+			UID:       testUID,
+		},
+		Spec: v1alpha1.CloudSchedulerSourceSpec{
+			GoogleCloudProject: testProject,
+			Location:           testLocation,
+			Schedule:           testSchedule,
+			Sink: &corev1.ObjectReference{
+				APIVersion: "eventing.knative.dev/v1alpha1",
+				Kind:       "Channel",
+				Name:       sinkName,
+			},
+		},
+	}
+	for _, opt := range opts {
+		opt(csr)
+	}
+	return csr
+}
+
+func withFinalizer(csr *v1alpha1.CloudSchedulerSource) {
+	csr.Finalizers = []string{finalizerName}
+}
+
+func withDeletionTimestamp(csr *v1alpha1.CloudSchedulerSource) {
+	csr.DeletionTimestamp = &deletionTime
+}
+
+func withAppEngineTarget(csr *v1alpha1.CloudSchedulerSource) {
+	csr.Spec.Sink = nil
+	csr.Spec.Body = "cleanup"
+	csr.Spec.AppEngineTarget = &v1alpha1.AppEngineTarget{
+		RelativeURI: "/tasks/cleanup",
+		Service:     "worker",
+		Version:     "v2",
+		Headers:     map[string]string{"X-Team": "billing"},
+	}
+}
+
+func withOIDCToken(csr *v1alpha1.CloudSchedulerSource) {
+	csr.Spec.OIDCToken = &v1alpha1.OIDCToken{ServiceAccountEmail: testServiceAccount}
+}
+
+func withHeaders(headers map[string]string) sourceOption {
+	return func(csr *v1alpha1.CloudSchedulerSource) {
+		csr.Spec.Headers = headers
+	}
+}
+
+func withDeadLetterSink(csr *v1alpha1.CloudSchedulerSource) {
+	csr.Spec.DeadLetterSink = &corev1.ObjectReference{
+		APIVersion: "eventing.knative.dev/v1alpha1",
+		Kind:       "Channel",
+		Name:       deadLetterSinkName,
+	}
+}
+
+func withEventAttributes(eventType, eventSource string) sourceOption {
+	return func(csr *v1alpha1.CloudSchedulerSource) {
+		csr.Spec.CloudEvents = &v1alpha1.CloudEventsFormat{Type: eventType, Source: eventSource}
+	}
+}
+
+func withSuspend(csr *v1alpha1.CloudSchedulerSource) {
+	csr.Spec.Suspend = true
+}
+
+func withStatusJob(name string) sourceOption {
+	return func(csr *v1alpha1.CloudSchedulerSource) {
+		csr.Status.Job = name
+	}
+}
+
+func withRetryConfig(rc *v1alpha1.RetryConfig) sourceOption {
+	return func(csr *v1alpha1.CloudSchedulerSource) {
+		csr.Spec.RetryConfig = rc
+	}
+}
+
+func withBackend(b v1alpha1.SchedulerBackend) sourceOption {
+	return func(csr *v1alpha1.CloudSchedulerSource) {
+		csr.Spec.Backend = b
+	}
+}
+
+// addressableSink returns a Channel with the given hostname. An empty
+// hostname results in a Channel without an address.
+func addressableSink(hostname string) *unstructured.Unstructured {
+	return namedAddressableSink(sinkName, hostname)
+}
+
+func namedAddressableSink(name, hostname string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "eventing.knative.dev/v1alpha1",
+			"kind":       "Channel",
+			"metadata": map[string]interface{}{
+				"namespace": testNS,
+				"name":      name,
+			},
+		},
+	}
+	if hostname != "" {
+		obj.Object["status"] = map[string]interface{}{
+			"address": map[string]interface{}{
+				"hostname": hostname,
+			},
+		}
+	}
+	return obj
+}
+
+func service(domain string) *servingv1alpha1.Service {
+	return serviceWithSink(domain, sinkURI)
+}
+
+// serviceWithSink returns the Receive Adapter Service of a source whose sink
+// resolved to the given URI.
+func serviceWithSink(domain, sink string) *servingv1alpha1.Service {
+	csr := source()
+	csr.SetDefaults()
+	csr.Status.SinkURI = sink
+	csr.Status.EventType = receiveadapter.EventType
+	csr.Status.EventSource = testEventSource
+	svc := resources.MakeService(csr, testImage)
+	svc.Status.Domain = domain
+	return svc
+}
+
+// job returns the job of the test source, as the reconciler creates it.
+func job(schedule, uri string) *schedulerpb.Job {
+	return withJobDescription(namedJob(testJobName, schedule, uri), jobDescription(source()))
+}
+
+// appEngineJob returns the job of the test source withAppEngineTarget.
+func appEngineJob() *schedulerpb.Job {
+	return &schedulerpb.Job{
+		Name:        testJobName,
+		Description: jobDescription(source()),
+		Schedule:    testSchedule,
+		TimeZone:    "UTC",
+		Target: &schedulerpb.Job_AppEngineHttpTarget{
+			AppEngineHttpTarget: &schedulerpb.AppEngineHttpTarget{
+				HttpMethod:  schedulerpb.HttpMethod_POST,
+				RelativeUri: "/tasks/cleanup",
+				AppEngineRouting: &schedulerpb.AppEngineRouting{
+					Service: "worker",
+					Version: "v2",
+				},
+				Headers: map[string]string{"X-Team": "billing"},
+				Body:    []byte("cleanup"),
+			},
+		},
+	}
+}
+
+// withAppEngineHost sets the host of the routing of an App Engine job, which
+// Cloud Scheduler fills in.
+func withAppEngineHost(job *schedulerpb.Job, host string) *schedulerpb.Job {
+	job.GetAppEngineHttpTarget().AppEngineRouting.Host = host
+	return job
+}
+
+func withJobDescription(job *schedulerpb.Job, description string) *schedulerpb.Job {
+	job.Description = description
+	return job
+}
+
+func withJobHeaders(job *schedulerpb.Job, headers map[string]string) *schedulerpb.Job {
+	job.GetHttpTarget().Headers = headers
+	return job
+}
+
+func withJobOIDCToken(job *schedulerpb.Job, email, audience string) *schedulerpb.Job {
+	job.GetHttpTarget().AuthorizationHeader = &schedulerpb.HttpTarget_OidcToken{
+		OidcToken: &schedulerpb.OidcToken{ServiceAccountEmail: email, Audience: audience},
+	}
+	return job
+}
+
+// withJobState sets the state of a job. The fake backend pauses seeded jobs
+// that are PAUSED.
+func withJobState(job *schedulerpb.Job, state schedulerpb.Job_State) *schedulerpb.Job {
+	job.State = state
+	return job
+}
+
+func withJobRetryConfig(job *schedulerpb.Job, rc *schedulerpb.RetryConfig) *schedulerpb.Job {
+	job.RetryConfig = rc
+	return job
+}
+
+func namedJob(name, schedule, uri string) *schedulerpb.Job {
+	return &schedulerpb.Job{
+		Name:     name,
+		Schedule: schedule,
+		TimeZone: "UTC",
+		Target: &schedulerpb.Job_HttpTarget{
+			HttpTarget: &schedulerpb.HttpTarget{
+				Uri:        uri,
+				HttpMethod: schedulerpb.HttpMethod_POST,
+			},
+		},
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
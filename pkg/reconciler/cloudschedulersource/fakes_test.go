@@ -0,0 +1,398 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudschedulersource
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/knative/pkg/apis/duck"
+	servingv1alpha1 "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	servingclientset "github.com/knative/serving/pkg/client/clientset/versioned"
+	servingv1alpha1client "github.com/knative/serving/pkg/client/clientset/versioned/typed/serving/v1alpha1"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+	batchv1beta1client "k8s.io/client-go/kubernetes/typed/batch/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/vaikas-google/csr/pkg/backend"
+	"github.com/vaikas-google/csr/pkg/testing/fakescheduler"
+)
+
+// fakeBackend is a backend.JobBackend backed by the fake Cloud Scheduler,
+// which can be made to fail individual methods.
+type fakeBackend struct {
+	*fakescheduler.Server
+	// errors are returned instead of calling the fake, keyed by method name.
+	errors map[string]error
+}
+
+var _ backend.JobBackend = (*fakeBackend)(nil)
+
+func newFakeBackend(errors map[string]error, jobs ...*schedulerpb.Job) *fakeBackend {
+	s := fakescheduler.New()
+	for _, job := range jobs {
+		parent := job.Name[:strings.LastIndex(job.Name, "/jobs/")]
+		if _, err := s.CreateJob(context.Background(), &schedulerpb.CreateJobRequest{Parent: parent, Job: job}); err != nil {
+			panic(err)
+		}
+		if job.State == schedulerpb.Job_PAUSED {
+			if _, err := s.PauseJob(context.Background(), &schedulerpb.PauseJobRequest{Name: job.Name}); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return &fakeBackend{Server: s, errors: errors}
+}
+
+func (b *fakeBackend) GetJob(ctx context.Context, req *schedulerpb.GetJobRequest) (*schedulerpb.Job, error) {
+	if err := b.errors["GetJob"]; err != nil {
+		return nil, err
+	}
+	return b.Server.GetJob(ctx, req)
+}
+
+func (b *fakeBackend) CreateJob(ctx context.Context, req *schedulerpb.CreateJobRequest) (*schedulerpb.Job, error) {
+	if err := b.errors["CreateJob"]; err != nil {
+		return nil, err
+	}
+	return b.Server.CreateJob(ctx, req)
+}
+
+func (b *fakeBackend) UpdateJob(ctx context.Context, req *schedulerpb.UpdateJobRequest) (*schedulerpb.Job, error) {
+	if err := b.errors["UpdateJob"]; err != nil {
+		return nil, err
+	}
+	return b.Server.UpdateJob(ctx, req)
+}
+
+func (b *fakeBackend) DeleteJob(ctx context.Context, req *schedulerpb.DeleteJobRequest) error {
+	if err := b.errors["DeleteJob"]; err != nil {
+		return err
+	}
+	_, err := b.Server.DeleteJob(ctx, req)
+	return err
+}
+
+// fakeDynamicClient is a dynamic.Interface that only supports getting the
+// objects it was seeded with.
+type fakeDynamicClient struct {
+	objects []*unstructured.Unstructured
+	// unknown are the resources the API server doesn't serve.
+	unknown []schema.GroupVersionResource
+}
+
+var _ dynamic.Interface = (*fakeDynamicClient)(nil)
+
+func (c *fakeDynamicClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &fakeDynamicResource{client: c, gvr: gvr}
+}
+
+type fakeDynamicResource struct {
+	dynamic.NamespaceableResourceInterface
+	client    *fakeDynamicClient
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+func (r *fakeDynamicResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &fakeDynamicResource{client: r.client, gvr: r.gvr, namespace: namespace}
+}
+
+func (r *fakeDynamicResource) Get(name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	for _, gvr := range r.client.unknown {
+		if gvr == r.gvr {
+			return nil, errors.NewGenericServerResponse(http.StatusNotFound, "get", gvr.GroupResource(), name, "404 page not found", 0, true)
+		}
+	}
+	for _, obj := range r.client.objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group == r.gvr.Group && gvk.Version == r.gvr.Version &&
+			obj.GetNamespace() == r.namespace && obj.GetName() == name {
+			return obj.DeepCopy(), nil
+		}
+	}
+	return nil, errors.NewNotFound(r.gvr.GroupResource(), name)
+}
+
+// fakeInformerFactory is a duck.InformerFactory that records the resources
+// informers were requested for, without starting any.
+type fakeInformerFactory struct {
+	resources []schema.GroupVersionResource
+}
+
+var _ duck.InformerFactory = (*fakeInformerFactory)(nil)
+
+func (f *fakeInformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	f.resources = append(f.resources, gvr)
+	return nil, nil, nil
+}
+
+// fakeServingClient is a servingclientset.Interface that only supports
+// getting, creating and updating Services.
+type fakeServingClient struct {
+	servingclientset.Interface
+	services *fakeServices
+}
+
+var _ servingclientset.Interface = (*fakeServingClient)(nil)
+
+func newFakeServingClient(errors map[string]error, services ...*servingv1alpha1.Service) *fakeServingClient {
+	fs := &fakeServices{
+		services: make(map[string]*servingv1alpha1.Service),
+		errors:   errors,
+	}
+	for _, svc := range services {
+		fs.services[svc.Namespace+"/"+svc.Name] = svc.DeepCopy()
+	}
+	return &fakeServingClient{services: fs}
+}
+
+func (c *fakeServingClient) ServingV1alpha1() servingv1alpha1client.ServingV1alpha1Interface {
+	return &fakeServingV1alpha1{services: c.services}
+}
+
+type fakeServingV1alpha1 struct {
+	servingv1alpha1client.ServingV1alpha1Interface
+	services *fakeServices
+}
+
+func (c *fakeServingV1alpha1) Services(namespace string) servingv1alpha1client.ServiceInterface {
+	return &fakeServiceClient{services: c.services, namespace: namespace}
+}
+
+// fakeServices holds the Services of a fakeServingClient.
+type fakeServices struct {
+	services map[string]*servingv1alpha1.Service
+	// errors are returned instead of calling the fake, keyed by method name.
+	errors map[string]error
+}
+
+type fakeServiceClient struct {
+	servingv1alpha1client.ServiceInterface
+	services  *fakeServices
+	namespace string
+}
+
+func (c *fakeServiceClient) Get(name string, options metav1.GetOptions) (*servingv1alpha1.Service, error) {
+	if err := c.services.errors["Get"]; err != nil {
+		return nil, err
+	}
+	svc, ok := c.services.services[c.namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(servingv1alpha1.Resource("services"), name)
+	}
+	return svc.DeepCopy(), nil
+}
+
+func (c *fakeServiceClient) Create(svc *servingv1alpha1.Service) (*servingv1alpha1.Service, error) {
+	if err := c.services.errors["Create"]; err != nil {
+		return nil, err
+	}
+	key := c.namespace + "/" + svc.Name
+	if _, ok := c.services.services[key]; ok {
+		return nil, errors.NewAlreadyExists(servingv1alpha1.Resource("services"), svc.Name)
+	}
+	c.services.services[key] = svc.DeepCopy()
+	return svc, nil
+}
+
+func (c *fakeServiceClient) Update(svc *servingv1alpha1.Service) (*servingv1alpha1.Service, error) {
+	if err := c.services.errors["Update"]; err != nil {
+		return nil, err
+	}
+	key := c.namespace + "/" + svc.Name
+	if _, ok := c.services.services[key]; !ok {
+		return nil, errors.NewNotFound(servingv1alpha1.Resource("services"), svc.Name)
+	}
+	c.services.services[key] = svc.DeepCopy()
+	return svc, nil
+}
+
+// fakePubSub is a backend.PubSub that keeps topics and subscriptions in
+// memory.
+type fakePubSub struct {
+	topics        map[string]*pubsubpb.Topic
+	subscriptions map[string]*pubsubpb.Subscription
+}
+
+var _ backend.PubSub = (*fakePubSub)(nil)
+
+func newFakePubSub() *fakePubSub {
+	return &fakePubSub{
+		topics:        make(map[string]*pubsubpb.Topic),
+		subscriptions: make(map[string]*pubsubpb.Subscription),
+	}
+}
+
+func (ps *fakePubSub) CreateTopic(ctx context.Context, req *pubsubpb.Topic) (*pubsubpb.Topic, error) {
+	if _, ok := ps.topics[req.Name]; ok {
+		return nil, gstatus.Errorf(codes.AlreadyExists, "topic %q already exists", req.Name)
+	}
+	ps.topics[req.Name] = req
+	return req, nil
+}
+
+func (ps *fakePubSub) DeleteTopic(ctx context.Context, req *pubsubpb.DeleteTopicRequest) error {
+	if _, ok := ps.topics[req.Topic]; !ok {
+		return gstatus.Errorf(codes.NotFound, "topic %q not found", req.Topic)
+	}
+	delete(ps.topics, req.Topic)
+	return nil
+}
+
+func (ps *fakePubSub) CreateSubscription(ctx context.Context, req *pubsubpb.Subscription) (*pubsubpb.Subscription, error) {
+	if _, ok := ps.topics[req.Topic]; !ok {
+		return nil, gstatus.Errorf(codes.NotFound, "topic %q not found", req.Topic)
+	}
+	if _, ok := ps.subscriptions[req.Name]; ok {
+		return nil, gstatus.Errorf(codes.AlreadyExists, "subscription %q already exists", req.Name)
+	}
+	ps.subscriptions[req.Name] = req
+	return req, nil
+}
+
+func (ps *fakePubSub) DeleteSubscription(ctx context.Context, req *pubsubpb.DeleteSubscriptionRequest) error {
+	if _, ok := ps.subscriptions[req.Subscription]; !ok {
+		return gstatus.Errorf(codes.NotFound, "subscription %q not found", req.Subscription)
+	}
+	delete(ps.subscriptions, req.Subscription)
+	return nil
+}
+
+// fakeKubeClient is a kubernetes.Interface that only supports getting,
+// creating and updating Deployments and CronJobs.
+type fakeKubeClient struct {
+	kubernetes.Interface
+	deployments map[string]*appsv1.Deployment
+	cronJobs    map[string]*batchv1beta1.CronJob
+}
+
+var _ kubernetes.Interface = (*fakeKubeClient)(nil)
+
+func newFakeKubeClient(deployments ...*appsv1.Deployment) *fakeKubeClient {
+	c := &fakeKubeClient{
+		deployments: make(map[string]*appsv1.Deployment),
+		cronJobs:    make(map[string]*batchv1beta1.CronJob),
+	}
+	for _, d := range deployments {
+		c.deployments[d.Namespace+"/"+d.Name] = d.DeepCopy()
+	}
+	return c
+}
+
+func (c *fakeKubeClient) AppsV1() appsv1client.AppsV1Interface {
+	return &fakeAppsV1{client: c}
+}
+
+type fakeAppsV1 struct {
+	appsv1client.AppsV1Interface
+	client *fakeKubeClient
+}
+
+func (c *fakeAppsV1) Deployments(namespace string) appsv1client.DeploymentInterface {
+	return &fakeDeploymentClient{client: c.client, namespace: namespace}
+}
+
+type fakeDeploymentClient struct {
+	appsv1client.DeploymentInterface
+	client    *fakeKubeClient
+	namespace string
+}
+
+func (c *fakeDeploymentClient) Get(name string, options metav1.GetOptions) (*appsv1.Deployment, error) {
+	d, ok := c.client.deployments[c.namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(appsv1.Resource("deployments"), name)
+	}
+	return d.DeepCopy(), nil
+}
+
+func (c *fakeDeploymentClient) Create(d *appsv1.Deployment) (*appsv1.Deployment, error) {
+	key := c.namespace + "/" + d.Name
+	if _, ok := c.client.deployments[key]; ok {
+		return nil, errors.NewAlreadyExists(appsv1.Resource("deployments"), d.Name)
+	}
+	c.client.deployments[key] = d.DeepCopy()
+	return d, nil
+}
+
+func (c *fakeDeploymentClient) Update(d *appsv1.Deployment) (*appsv1.Deployment, error) {
+	key := c.namespace + "/" + d.Name
+	if _, ok := c.client.deployments[key]; !ok {
+		return nil, errors.NewNotFound(appsv1.Resource("deployments"), d.Name)
+	}
+	c.client.deployments[key] = d.DeepCopy()
+	return d, nil
+}
+
+func (c *fakeKubeClient) BatchV1beta1() batchv1beta1client.BatchV1beta1Interface {
+	return &fakeBatchV1beta1{client: c}
+}
+
+type fakeBatchV1beta1 struct {
+	batchv1beta1client.BatchV1beta1Interface
+	client *fakeKubeClient
+}
+
+func (c *fakeBatchV1beta1) CronJobs(namespace string) batchv1beta1client.CronJobInterface {
+	return &fakeCronJobClient{client: c.client, namespace: namespace}
+}
+
+type fakeCronJobClient struct {
+	batchv1beta1client.CronJobInterface
+	client    *fakeKubeClient
+	namespace string
+}
+
+func (c *fakeCronJobClient) Get(name string, options metav1.GetOptions) (*batchv1beta1.CronJob, error) {
+	cj, ok := c.client.cronJobs[c.namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(batchv1beta1.Resource("cronjobs"), name)
+	}
+	return cj.DeepCopy(), nil
+}
+
+func (c *fakeCronJobClient) Create(cj *batchv1beta1.CronJob) (*batchv1beta1.CronJob, error) {
+	key := c.namespace + "/" + cj.Name
+	if _, ok := c.client.cronJobs[key]; ok {
+		return nil, errors.NewAlreadyExists(batchv1beta1.Resource("cronjobs"), cj.Name)
+	}
+	c.client.cronJobs[key] = cj.DeepCopy()
+	return cj, nil
+}
+
+func (c *fakeCronJobClient) Update(cj *batchv1beta1.CronJob) (*batchv1beta1.CronJob, error) {
+	key := c.namespace + "/" + cj.Name
+	if _, ok := c.client.cronJobs[key]; !ok {
+		return nil, errors.NewNotFound(batchv1beta1.Resource("cronjobs"), cj.Name)
+	}
+	c.client.cronJobs[key] = cj.DeepCopy()
+	return cj, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	"github.com/knative/pkg/kmeta"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+)
+
+// MakeCronJob creates the spec for, but does not create, a CronJob that
+// sends the events of a given CloudSchedulerSource to its sink. Each run
+// uses the Receive Adapter image to post a single event.
+func MakeCronJob(source *v1alpha1.CloudSchedulerSource, receiveAdapterImage string) *batchv1beta1.CronJob {
+	labels := map[string]string{
+		"receive-adapter": "cloudschedulersource",
+	}
+	containerArgs := []string{
+		fmt.Sprintf("--sink=%s", source.Status.SinkURI),
+		"--once",
+		fmt.Sprintf("--body=%s", source.Spec.Body),
+	}
+	containerArgs = append(containerArgs, cloudEventsArgs(source)...)
+	containerArgs = append(containerArgs, deliveryArgs(source)...)
+	env := []corev1.EnvVar{
+		{
+			// Retries of a run share the Job, so use its name as the event ID.
+			Name: "EVENT_ID",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.labels['job-name']",
+				},
+			},
+		},
+	}
+	suspend := source.Spec.Suspend
+	return &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CronJobName(source),
+			Namespace: source.Namespace,
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*kmeta.NewControllerRef(source),
+			},
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule: source.Spec.Schedule,
+			Suspend:  &suspend,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: source.Spec.ServiceAccountName,
+							RestartPolicy:      corev1.RestartPolicyOnFailure,
+							Containers: []corev1.Container{
+								{
+									Name:  "receive-adapter",
+									Image: receiveAdapterImage,
+									Env:   env,
+									Args:  containerArgs,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
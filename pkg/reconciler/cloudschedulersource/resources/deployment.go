@@ -0,0 +1,101 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/knative/pkg/kmeta"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+)
+
+const credentialsVolumeName = "google-cloud-credentials"
+
+// MakeDeployment creates the spec for, but does not create, a Deployment
+// (Receive Adapter) that pulls the events of a given CloudSchedulerSource
+// with a PubSubTarget from the given subscription and sends them to its
+// sink.
+func MakeDeployment(source *v1alpha1.CloudSchedulerSource, receiveAdapterImage, subscription string) *appsv1.Deployment {
+	labels := map[string]string{
+		"receive-adapter": "cloudschedulersource",
+		"source":          source.Name,
+	}
+	containerArgs := []string{
+		fmt.Sprintf("--sink=%s", source.Status.SinkURI),
+		fmt.Sprintf("--subscription=%s", subscription),
+		fmt.Sprintf("--job-name=%s", JobID(source)),
+	}
+	containerArgs = append(containerArgs, cloudEventsArgs(source)...)
+	containerArgs = append(containerArgs, deliveryArgs(source)...)
+	container := corev1.Container{
+		Name:  "receive-adapter",
+		Image: receiveAdapterImage,
+		Args:  containerArgs,
+	}
+	var volumes []corev1.Volume
+	if secret := source.Spec.PubSubTarget.CredentialsSecret; secret != nil {
+		mountPath := "/var/secrets/google"
+		volumes = append(volumes, corev1.Volume{
+			Name: credentialsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: secret.Name,
+				},
+			},
+		})
+		container.VolumeMounts = []corev1.VolumeMount{{
+			Name:      credentialsVolumeName,
+			MountPath: mountPath,
+		}}
+		container.Env = []corev1.EnvVar{{
+			Name:  "GOOGLE_APPLICATION_CREDENTIALS",
+			Value: path.Join(mountPath, secret.Key),
+		}}
+	}
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*kmeta.NewControllerRef(source),
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: source.Spec.ServiceAccountName,
+					Containers:         []corev1.Container{container},
+					Volumes:            volumes,
+				},
+			},
+		},
+	}
+}
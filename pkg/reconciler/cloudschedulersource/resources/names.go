@@ -0,0 +1,62 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+)
+
+// JobID returns the ID of the scheduler job for a given CloudSchedulerSource.
+// Job IDs may only contain letters, numbers, hyphens and underscores, and be
+// at most 500 characters long. Underscores can't appear in Kubernetes names,
+// so they separate the namespace, name and UID unambiguously, and with the
+// UID included a recreated source doesn't pick up the job of its predecessor.
+// The longest possible ID is 63+253+36+2 characters.
+func JobID(source *v1alpha1.CloudSchedulerSource) string {
+	return fmt.Sprintf("%s_%s_%s",
+		source.Namespace,
+		strings.Replace(source.Name, ".", "-", -1),
+		source.UID)
+}
+
+// TopicID returns the ID of the Pub/Sub topic, and of its subscription, for
+// a given CloudSchedulerSource with a PubSubTarget. IDs must start with a
+// letter and may not start with "goog". The UID is unique and keeps the ID
+// well below the limit of 255 characters.
+func TopicID(source *v1alpha1.CloudSchedulerSource) string {
+	return fmt.Sprintf("cloudschedulersource-%s", source.UID)
+}
+
+// maxCronJobNameLength is the longest name the API server accepts for a
+// CronJob, which leaves room for the suffix of the Jobs it creates.
+const maxCronJobNameLength = 52
+
+// CronJobName returns the name of the CronJob for a given
+// CloudSchedulerSource. Names that are too long are truncated, and a hash of
+// the full name keeps them unique.
+func CronJobName(source *v1alpha1.CloudSchedulerSource) string {
+	if len(source.Name) <= maxCronJobNameLength {
+		return source.Name
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(source.Name)))[:8]
+	prefix := strings.TrimRight(source.Name[:maxCronJobNameLength-len(hash)-1], "-.")
+	return prefix + "-" + hash
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+)
+
+func TestCronJobName(t *testing.T) {
+	long := strings.Repeat("a", 50) + ".b" + strings.Repeat("c", 50)
+	names := map[string]bool{}
+	for _, name := range []string{
+		"short",
+		strings.Repeat("a", 52),
+		strings.Repeat("a", 53),
+		strings.Repeat("a", 54),
+		long,
+		long + "d",
+		strings.Repeat("a", 42) + ".-" + strings.Repeat("b", 20),
+	} {
+		source := &v1alpha1.CloudSchedulerSource{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		got := CronJobName(source)
+		if len(name) <= 52 && got != name {
+			t.Errorf("CronJobName(%q) = %q, want the name as is", name, got)
+		}
+		if len(got) > 52 {
+			t.Errorf("CronJobName(%q) = %q, want at most 52 characters", name, got)
+		}
+		if errs := validation.IsDNS1123Subdomain(got); len(errs) > 0 {
+			t.Errorf("CronJobName(%q) = %q, an invalid name: %v", name, got, errs)
+		}
+		if names[got] {
+			t.Errorf("CronJobName(%q) = %q, which isn't unique", name, got)
+		}
+		names[got] = true
+	}
+}
@@ -18,6 +18,7 @@ package resources
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/knative/pkg/kmeta"
 	corev1 "k8s.io/api/core/v1"
@@ -41,6 +42,19 @@ func MakeService(source *v1alpha1.CloudSchedulerSource, receiveAdapterImage stri
 		},
 	}
 	containerArgs := []string{fmt.Sprintf("--sink=%s", sinkURI)}
+	containerArgs = append(containerArgs, cloudEventsArgs(source)...)
+	containerArgs = append(containerArgs, deliveryArgs(source)...)
+	if len(source.Spec.ForwardHeaders) > 0 {
+		containerArgs = append(containerArgs, fmt.Sprintf("--forward-headers=%s", strings.Join(source.Spec.ForwardHeaders, ",")))
+	}
+	if oidc := source.Spec.OIDCToken; oidc != nil {
+		// Without an audience the Receive Adapter expects its own URL,
+		// which is what the job uses then.
+		containerArgs = append(containerArgs, fmt.Sprintf("--oidc-email=%s", oidc.ServiceAccountEmail))
+		if oidc.Audience != "" {
+			containerArgs = append(containerArgs, fmt.Sprintf("--oidc-audience=%s", oidc.Audience))
+		}
+	}
 	return &servingv1alpha1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      source.Name,
@@ -68,3 +82,67 @@ func MakeService(source *v1alpha1.CloudSchedulerSource, receiveAdapterImage stri
 		},
 	}
 }
+
+// cloudEventsArgs returns the Receive Adapter arguments for the attributes
+// of the events of a given CloudSchedulerSource, and how it encodes them.
+func cloudEventsArgs(source *v1alpha1.CloudSchedulerSource) []string {
+	var args []string
+	if source.Status.EventType != "" {
+		args = append(args, fmt.Sprintf("--event-type=%s", source.Status.EventType))
+	}
+	if source.Status.EventSource != "" {
+		args = append(args, fmt.Sprintf("--event-source=%s", source.Status.EventSource))
+	}
+	ce := source.Spec.CloudEvents
+	if ce == nil {
+		return args
+	}
+	if ce.SpecVersion != "" {
+		args = append(args, fmt.Sprintf("--cloudevents-spec-version=%s", ce.SpecVersion))
+	}
+	if ce.Encoding != "" {
+		args = append(args, fmt.Sprintf("--cloudevents-encoding=%s", ce.Encoding))
+	}
+	return args
+}
+
+// deliveryArgs returns the Receive Adapter arguments for how a given
+// CloudSchedulerSource delivers events that the sink doesn't accept right
+// away, or that were delivered already. Unset fields are left to the
+// defaults of the Receive Adapter.
+func deliveryArgs(source *v1alpha1.CloudSchedulerSource) []string {
+	var args []string
+	if source.Status.DeadLetterSinkURI != "" {
+		args = append(args, fmt.Sprintf("--dead-letter-sink=%s", source.Status.DeadLetterSinkURI))
+	}
+	if d := source.Spec.Deduplication; d != nil {
+		args = append(args, fmt.Sprintf("--dedup-cache-size=%d", d.CacheSize))
+		if d.TTL != nil {
+			args = append(args, fmt.Sprintf("--dedup-ttl=%s", d.TTL.Duration))
+		}
+	}
+	sr := source.Spec.SinkRetry
+	if sr == nil {
+		return args
+	}
+	if sr.MaxAttempts != 0 {
+		args = append(args, fmt.Sprintf("--retry-max-attempts=%d", sr.MaxAttempts))
+	}
+	if sr.MinBackoffDuration != nil {
+		args = append(args, fmt.Sprintf("--retry-min-backoff=%s", sr.MinBackoffDuration.Duration))
+	}
+	if sr.MaxBackoffDuration != nil {
+		args = append(args, fmt.Sprintf("--retry-max-backoff=%s", sr.MaxBackoffDuration.Duration))
+	}
+	if sr.JitterPercent != nil {
+		args = append(args, fmt.Sprintf("--retry-jitter-percent=%d", *sr.JitterPercent))
+	}
+	if len(sr.RetryableStatusCodes) > 0 {
+		codes := make([]string, len(sr.RetryableStatusCodes))
+		for i, c := range sr.RetryableStatusCodes {
+			codes[i] = fmt.Sprint(c)
+		}
+		args = append(args, fmt.Sprintf("--retry-status-codes=%s", strings.Join(codes, ",")))
+	}
+	return args
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/knative/pkg/apis/duck"
 	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/dynamic"
 )
@@ -71,3 +73,29 @@ func createResourceInterface(dc dynamic.Interface, ref *corev1.ObjectReference,
 	}
 	return rc.Namespace(namespace), nil
 }
+
+// isUnknownResource returns true if err means that the resource of a sink
+// isn't served by the API server at all, as opposed to the sink not existing
+// (yet).
+func isUnknownResource(err error) bool {
+	if meta.IsNoMatchError(err) {
+		return true
+	}
+	status, ok := err.(errors.APIStatus)
+	if !ok || status.Status().Reason != metav1.StatusReasonNotFound {
+		return false
+	}
+	// Missing objects are reported along with their name. Unknown resources
+	// get a generic 404, either as a Status without details or as a page
+	// that isn't a Status at all.
+	details := status.Status().Details
+	if details == nil || details.Name == "" {
+		return true
+	}
+	for _, cause := range details.Causes {
+		if cause.Type == metav1.CauseTypeUnexpectedServerResponse {
+			return true
+		}
+	}
+	return false
+}
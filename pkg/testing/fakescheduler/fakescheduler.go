@@ -0,0 +1,276 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakescheduler provides an in-memory implementation of the Cloud
+// Scheduler gRPC API for use in tests and local development.
+package fakescheduler
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/api/option"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+
+	"github.com/vaikas-google/csr/pkg/backend"
+)
+
+// Server is an in-memory Cloud Scheduler. Jobs are never executed on their
+// schedule, only when RunJob is called, in which case HTTP targets are
+// invoked synchronously.
+type Server struct {
+	// Client is used to dispatch HTTP targets from RunJob. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	mu   sync.Mutex
+	jobs map[string]*schedulerpb.Job
+}
+
+// Check that we implement the CloudSchedulerServer interface.
+var _ schedulerpb.CloudSchedulerServer = (*Server)(nil)
+
+// New returns an empty fake Cloud Scheduler.
+func New() *Server {
+	return &Server{
+		jobs: make(map[string]*schedulerpb.Job),
+	}
+}
+
+// Start serves the fake over gRPC on the given address, for example
+// "localhost:0". It returns the address that is being listened on and a
+// function that stops the server.
+func (s *Server) Start(addr string) (string, func(), error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, err
+	}
+	gs := grpc.NewServer()
+	schedulerpb.RegisterCloudSchedulerServer(gs, s)
+	go gs.Serve(lis)
+	return lis.Addr().String(), gs.Stop, nil
+}
+
+// ClientOptions returns the options for pointing a Cloud Scheduler client at
+// a fake listening on the given address.
+func ClientOptions(addr string) []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+	}
+}
+
+// Jobs returns a copy of all the jobs currently known to the fake, ordered
+// by name.
+func (s *Server) Jobs() []*schedulerpb.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list("")
+}
+
+// ListJobs implements schedulerpb.CloudSchedulerServer. Paging is not
+// supported, all the jobs under the parent are returned at once.
+func (s *Server) ListJobs(ctx context.Context, req *schedulerpb.ListJobsRequest) (*schedulerpb.ListJobsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &schedulerpb.ListJobsResponse{
+		Jobs: s.list(req.Parent + "/jobs/"),
+	}, nil
+}
+
+// GetJob implements schedulerpb.CloudSchedulerServer.
+func (s *Server) GetJob(ctx context.Context, req *schedulerpb.GetJobRequest) (*schedulerpb.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, err := s.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return clone(job), nil
+}
+
+// CreateJob implements schedulerpb.CloudSchedulerServer.
+func (s *Server) CreateJob(ctx context.Context, req *schedulerpb.CreateJobRequest) (*schedulerpb.Job, error) {
+	if req.Parent == "" {
+		return nil, gstatus.Error(codes.InvalidArgument, "parent is required")
+	}
+	if req.Job == nil {
+		return nil, gstatus.Error(codes.InvalidArgument, "job is required")
+	}
+	if !strings.HasPrefix(req.Job.Name, req.Parent+"/jobs/") {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "job name %q is not under parent %q", req.Job.Name, req.Parent)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[req.Job.Name]; ok {
+		return nil, gstatus.Errorf(codes.AlreadyExists, "job %q already exists", req.Job.Name)
+	}
+	job := clone(req.Job)
+	job.State = schedulerpb.Job_ENABLED
+	job.UserUpdateTime = ptypes.TimestampNow()
+	s.jobs[job.Name] = job
+	return clone(job), nil
+}
+
+// UpdateJob implements schedulerpb.CloudSchedulerServer. If an update mask
+// is given only the named top level fields are updated, otherwise all the
+// user settable fields are replaced.
+func (s *Server) UpdateJob(ctx context.Context, req *schedulerpb.UpdateJobRequest) (*schedulerpb.Job, error) {
+	if req.Job == nil {
+		return nil, gstatus.Error(codes.InvalidArgument, "job is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := s.get(req.Job.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{"description", "target", "schedule", "time_zone", "retry_config"}
+	if req.UpdateMask != nil && len(req.UpdateMask.Paths) > 0 {
+		paths = req.UpdateMask.Paths
+	}
+	job := clone(existing)
+	for _, p := range paths {
+		switch p {
+		case "description":
+			job.Description = req.Job.Description
+		case "target", "http_target", "pubsub_target", "app_engine_http_target":
+			job.Target = clone(req.Job).Target
+		case "schedule":
+			job.Schedule = req.Job.Schedule
+		case "time_zone":
+			job.TimeZone = req.Job.TimeZone
+		case "retry_config":
+			job.RetryConfig = clone(req.Job).RetryConfig
+		default:
+			return nil, gstatus.Errorf(codes.InvalidArgument, "unsupported update mask path %q", p)
+		}
+	}
+	job.UserUpdateTime = ptypes.TimestampNow()
+	s.jobs[job.Name] = job
+	return clone(job), nil
+}
+
+// DeleteJob implements schedulerpb.CloudSchedulerServer.
+func (s *Server) DeleteJob(ctx context.Context, req *schedulerpb.DeleteJobRequest) (*empty.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(req.Name); err != nil {
+		return nil, err
+	}
+	delete(s.jobs, req.Name)
+	return &empty.Empty{}, nil
+}
+
+// PauseJob implements schedulerpb.CloudSchedulerServer.
+func (s *Server) PauseJob(ctx context.Context, req *schedulerpb.PauseJobRequest) (*schedulerpb.Job, error) {
+	return s.transition(req.Name, schedulerpb.Job_ENABLED, schedulerpb.Job_PAUSED)
+}
+
+// ResumeJob implements schedulerpb.CloudSchedulerServer.
+func (s *Server) ResumeJob(ctx context.Context, req *schedulerpb.ResumeJobRequest) (*schedulerpb.Job, error) {
+	return s.transition(req.Name, schedulerpb.Job_PAUSED, schedulerpb.Job_ENABLED)
+}
+
+// RunJob implements schedulerpb.CloudSchedulerServer. Only HTTP targets
+// are dispatched, and the outcome is recorded in the job's Status.
+func (s *Server) RunJob(ctx context.Context, req *schedulerpb.RunJobRequest) (*schedulerpb.Job, error) {
+	s.mu.Lock()
+	job, err := s.get(req.Name)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	job = clone(job)
+	s.mu.Unlock()
+
+	target := job.GetHttpTarget()
+	if target == nil {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "job %q does not have an HTTP target", req.Name)
+	}
+	now := time.Now()
+	runErr := backend.CallHTTPTarget(ctx, s.Client, job.Name, now, target)
+	if runErr != nil {
+		log.Printf("Failed to run job %q: %s", job.Name, runErr)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The job may have been deleted while it was running.
+	current, err := s.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	current.LastAttemptTime, _ = ptypes.TimestampProto(now)
+	current.Status = gstatus.Convert(runErr).Proto()
+	return clone(current), nil
+}
+
+func (s *Server) transition(name string, from, to schedulerpb.Job_State) (*schedulerpb.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if job.State != from {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "job %q is %s, not %s", name, job.State, from)
+	}
+	job.State = to
+	return clone(job), nil
+}
+
+// get returns the stored job with the given name. Callers must hold s.mu.
+func (s *Server) get(name string) (*schedulerpb.Job, error) {
+	job, ok := s.jobs[name]
+	if !ok {
+		return nil, gstatus.Errorf(codes.NotFound, "job %q not found", name)
+	}
+	return job, nil
+}
+
+// list returns copies of the stored jobs whose names start with the given
+// prefix, ordered by name. Callers must hold s.mu.
+func (s *Server) list(prefix string) []*schedulerpb.Job {
+	jobs := []*schedulerpb.Job{}
+	for name, job := range s.jobs {
+		if strings.HasPrefix(name, prefix) {
+			jobs = append(jobs, clone(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+func clone(job *schedulerpb.Job) *schedulerpb.Job {
+	return proto.Clone(job).(*schedulerpb.Job)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakescheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	scheduler "cloud.google.com/go/scheduler/apiv1beta1"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1beta1"
+	"google.golang.org/grpc/codes"
+	gstatus "google.golang.org/grpc/status"
+)
+
+const (
+	testParent  = "projects/testproject/locations/us-central1"
+	testJobName = testParent + "/jobs/testjob"
+)
+
+func TestServer(t *testing.T) {
+	var calls []*http.Request
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r)
+	}))
+	defer target.Close()
+
+	addr, stop, err := New().Start("localhost:0")
+	if err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer stop()
+	ctx := context.Background()
+	client, err := scheduler.NewCloudSchedulerClient(ctx, ClientOptions(addr)...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	job, err := client.CreateJob(ctx, &schedulerpb.CreateJobRequest{
+		Parent: testParent,
+		Job: &schedulerpb.Job{
+			Name:     testJobName,
+			Schedule: "*/5 * * * *",
+			Target: &schedulerpb.Job_HttpTarget{
+				HttpTarget: &schedulerpb.HttpTarget{
+					Uri:     target.URL,
+					Headers: map[string]string{"X-Team": "billing"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateJob() = %v", err)
+	}
+	if job.State != schedulerpb.Job_ENABLED {
+		t.Errorf("State = %s, want %s", job.State, schedulerpb.Job_ENABLED)
+	}
+	if _, err := client.CreateJob(ctx, &schedulerpb.CreateJobRequest{Parent: testParent, Job: job}); gstatus.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateJob() of an existing job = %v, want AlreadyExists", err)
+	}
+
+	if job, err = client.RunJob(ctx, &schedulerpb.RunJobRequest{Name: testJobName}); err != nil {
+		t.Fatalf("RunJob() = %v", err)
+	}
+	if job.Status.GetCode() != int32(codes.OK) {
+		t.Errorf("Status = %v, want OK", job.Status)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("Target got %d calls, want 1", len(calls))
+	}
+	for h, want := range map[string]string{
+		"X-Team":                   "billing",
+		"X-CloudScheduler":         "true",
+		"X-CloudScheduler-JobName": "testjob",
+	} {
+		if got := calls[0].Header.Get(h); got != want {
+			t.Errorf("Header %s = %q, want %q", h, got, want)
+		}
+	}
+
+	if job, err = client.PauseJob(ctx, &schedulerpb.PauseJobRequest{Name: testJobName}); err != nil || job.State != schedulerpb.Job_PAUSED {
+		t.Errorf("PauseJob() = (%v, %v), want a PAUSED job", job, err)
+	}
+	if job, err = client.ResumeJob(ctx, &schedulerpb.ResumeJobRequest{Name: testJobName}); err != nil || job.State != schedulerpb.Job_ENABLED {
+		t.Errorf("ResumeJob() = (%v, %v), want an ENABLED job", job, err)
+	}
+
+	if err := client.DeleteJob(ctx, &schedulerpb.DeleteJobRequest{Name: testJobName}); err != nil {
+		t.Fatalf("DeleteJob() = %v", err)
+	}
+	if _, err := client.GetJob(ctx, &schedulerpb.GetJobRequest{Name: testJobName}); gstatus.Code(err) != codes.NotFound {
+		t.Errorf("GetJob() of a deleted job = %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracker keeps track of the objects that reconciled objects refer
+// to, such as the sinks of sources, so that the referring objects can be
+// reconciled again when the objects they refer to change. It follows
+// knative/pkg/tracker.
+package tracker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Interface defines the interface through which an object can register
+// that it is tracking another object by reference.
+type Interface interface {
+	// Track tells us that "obj" is tracking changes to the
+	// referenced object.
+	Track(ref corev1.ObjectReference, obj interface{}) error
+
+	// OnChanged is a callback to register with the InformerFactory
+	// so that we are notified for appropriate object changes.
+	OnChanged(obj interface{})
+}
+
+// New returns an implementation of Interface that lets a Reconciler
+// register a particular resource as watching an ObjectReference for
+// a particular lease duration. This watch must be refreshed
+// periodically (e.g. by a controller resync) or it will expire.
+//
+// When OnChanged is called by the informer for a particular
+// GroupVersionKind, the provided callback is called with the key of each
+// object actively watching the changed object.
+func New(callback func(string), lease time.Duration) Interface {
+	return &impl{
+		leaseDuration: lease,
+		cb:            callback,
+	}
+}
+
+type set map[string]time.Time
+
+type impl struct {
+	m sync.Mutex
+	// mapping maps from an object reference to the set of
+	// keys for objects watching it.
+	mapping map[corev1.ObjectReference]set
+
+	// The amount of time that an object may watch another
+	// before having to renew the lease.
+	leaseDuration time.Duration
+
+	cb func(string)
+}
+
+// Check that impl implements Interface.
+var _ Interface = (*impl)(nil)
+
+// Track implements Interface.
+func (i *impl) Track(ref corev1.ObjectReference, obj interface{}) error {
+	invalidFields := map[string]string{
+		"APIVersion": ref.APIVersion,
+		"Kind":       ref.Kind,
+		"Namespace":  ref.Namespace,
+		"Name":       ref.Name,
+	}
+	for k, v := range invalidFields {
+		if v == "" {
+			return fmt.Errorf("reference is missing %s: %+v", k, ref)
+		}
+	}
+	// Only the fields that identify the object are used as the key.
+	ref = corev1.ObjectReference{
+		APIVersion: ref.APIVersion,
+		Kind:       ref.Kind,
+		Namespace:  ref.Namespace,
+		Name:       ref.Name,
+	}
+
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return err
+	}
+
+	i.m.Lock()
+	defer i.m.Unlock()
+	if i.mapping == nil {
+		i.mapping = make(map[corev1.ObjectReference]set)
+	}
+
+	l, ok := i.mapping[ref]
+	if !ok {
+		l = set{}
+	}
+	// Overwrite the key with a new expiration.
+	l[key] = time.Now().Add(i.leaseDuration)
+
+	i.mapping[ref] = l
+	return nil
+}
+
+func isExpired(expiry time.Time) bool {
+	return time.Now().After(expiry)
+}
+
+// OnChanged implements Interface.
+func (i *impl) OnChanged(obj interface{}) {
+	item, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	or, ok := obj.(runtime.Object)
+	if !ok {
+		return
+	}
+
+	gvk := or.GetObjectKind().GroupVersionKind()
+	ref := corev1.ObjectReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  item.GetNamespace(),
+		Name:       item.GetName(),
+	}
+
+	i.m.Lock()
+	defer i.m.Unlock()
+	s, ok := i.mapping[ref]
+	if !ok {
+		// Nobody is tracking the object.
+		return
+	}
+
+	for key, expiry := range s {
+		// If the expiration has lapsed, then delete the key.
+		if isExpired(expiry) {
+			delete(s, key)
+			continue
+		}
+		i.cb(key)
+	}
+
+	if len(s) == 0 {
+		delete(i.mapping, ref)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var channelRef = corev1.ObjectReference{
+	APIVersion: "eventing.knative.dev/v1alpha1",
+	Kind:       "Channel",
+	Namespace:  "ns",
+	Name:       "channel",
+}
+
+func channel(namespace, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "eventing.knative.dev/v1alpha1",
+		"kind":       "Channel",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		},
+	}}
+}
+
+func tracking(name string) *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{Namespace: "ns", Name: name}
+}
+
+func TestTrack(t *testing.T) {
+	var got []string
+	tr := New(func(key string) { got = append(got, key) }, time.Hour)
+
+	// Only the fields identifying the object count.
+	ref := channelRef
+	ref.UID = "1234"
+	ref.ResourceVersion = "5"
+	if err := tr.Track(ref, tracking("source")); err != nil {
+		t.Fatalf("Track() = %v", err)
+	}
+
+	tr.OnChanged(channel("ns", "other"))
+	tr.OnChanged(channel("other", "channel"))
+	if len(got) != 0 {
+		t.Errorf("Changes to other objects enqueued %v, want nothing", got)
+	}
+
+	tr.OnChanged(channel("ns", "channel"))
+	if len(got) != 1 || got[0] != "ns/source" {
+		t.Errorf("Change to the tracked object enqueued %v, want [ns/source]", got)
+	}
+
+	// Objects that aren't Kubernetes objects are ignored.
+	tr.OnChanged("channel")
+}
+
+func TestTrackExpires(t *testing.T) {
+	var got []string
+	tr := New(func(key string) { got = append(got, key) }, -time.Second)
+	if err := tr.Track(channelRef, tracking("source")); err != nil {
+		t.Fatalf("Track() = %v", err)
+	}
+	tr.OnChanged(channel("ns", "channel"))
+	if len(got) != 0 {
+		t.Errorf("Expired lease enqueued %v, want nothing", got)
+	}
+	if len(tr.(*impl).mapping) != 0 {
+		t.Errorf("Mapping = %v, want the expired lease dropped", tr.(*impl).mapping)
+	}
+}
+
+func TestTrackInvalidReference(t *testing.T) {
+	tr := New(func(string) {}, time.Hour)
+	for _, unset := range []func(*corev1.ObjectReference){
+		func(r *corev1.ObjectReference) { r.APIVersion = "" },
+		func(r *corev1.ObjectReference) { r.Kind = "" },
+		func(r *corev1.ObjectReference) { r.Namespace = "" },
+		func(r *corev1.ObjectReference) { r.Name = "" },
+	} {
+		ref := channelRef
+		unset(&ref)
+		if err := tr.Track(ref, tracking("source")); err == nil {
+			t.Errorf("Track(%+v) = nil, want an error", ref)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	organization = "knative.dev"
+	certValidity = 365 * 24 * time.Hour
+)
+
+// createCertTemplate returns a certificate template valid for a year.
+func createCertTemplate() (*x509.Certificate, error) {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{organization}},
+		SignatureAlgorithm:    x509.SHA256WithRSA,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(certValidity),
+		BasicConstraintsValid: true,
+	}, nil
+}
+
+// createCACertTemplate returns the template of the self-signed CA.
+func createCACertTemplate() (*x509.Certificate, error) {
+	rootCert, err := createCertTemplate()
+	if err != nil {
+		return nil, err
+	}
+	rootCert.IsCA = true
+	rootCert.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	rootCert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	return rootCert, nil
+}
+
+// createServerCertTemplate returns the template of the serving certificate
+// for the given service.
+func createServerCertTemplate(name, namespace string) (*x509.Certificate, error) {
+	serverCert, err := createCertTemplate()
+	if err != nil {
+		return nil, err
+	}
+	serviceName := name + "." + namespace
+	serverCert.Subject.CommonName = serviceName + ".svc"
+	serverCert.DNSNames = []string{serviceName, serviceName + ".svc", serviceName + ".svc.cluster.local"}
+	serverCert.KeyUsage = x509.KeyUsageDigitalSignature
+	serverCert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	return serverCert, nil
+}
+
+// createCert signs template with the given parent and returns it PEM encoded.
+func createCert(template, parent *x509.Certificate, pub *rsa.PublicKey, parentKey *rsa.PrivateKey) (*x509.Certificate, []byte, error) {
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	return cert, certPEM, nil
+}
+
+// CreateCerts creates a self-signed CA and a certificate signed by it for the
+// Kubernetes service with the given name and namespace. The server key, the
+// server certificate and the CA certificate are returned PEM encoded.
+func CreateCerts(name, namespace string) (serverKey, serverCert, caCert []byte, err error) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate the CA key: %v", err)
+	}
+	caTemplate, err := createCACertTemplate()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ca, caPEM, err := createCert(caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create the CA certificate: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate the server key: %v", err)
+	}
+	serverTemplate, err := createServerCertTemplate(name, namespace)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, serverPEM, err := createCert(serverTemplate, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create the server certificate: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	return keyPEM, serverPEM, caPEM, nil
+}
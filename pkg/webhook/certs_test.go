@@ -0,0 +1,64 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestCreateCerts(t *testing.T) {
+	serverKey, serverCert, caCert, err := CreateCerts("webhook", "system")
+	if err != nil {
+		t.Fatalf("CreateCerts() = %v", err)
+	}
+
+	// The key has to match the certificate for the webhook to serve TLS.
+	if _, err := tls.X509KeyPair(serverCert, serverKey); err != nil {
+		t.Fatalf("X509KeyPair() = %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCert) {
+		t.Fatalf("Invalid CA certificate %s", caCert)
+	}
+	block, _ := pem.Decode(serverCert)
+	if block == nil {
+		t.Fatalf("Invalid server certificate %s", serverCert)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate() = %v", err)
+	}
+	for _, name := range []string{"webhook.system", "webhook.system.svc", "webhook.system.svc.cluster.local"} {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("Verify(%s) = %v", name, err)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "other.system.svc", Roots: roots}); err == nil {
+		t.Error("Verify(other.system.svc) = nil, want an error")
+	}
+	if cert.NotAfter.Before(time.Now().Add(364 * 24 * time.Hour)) {
+		t.Errorf("NotAfter = %v, want a year from now", cert.NotAfter)
+	}
+	if cert.IsCA {
+		t.Error("Server certificate is a CA")
+	}
+}
@@ -0,0 +1,432 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook implements the admission webhooks that default and validate
+// the custom resources of this repository, in the style of
+// knative/pkg/webhook.
+package webhook
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/knative/pkg/apis"
+	"github.com/mattbaird/jsonpatch"
+	"go.uber.org/zap"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	secretServerKey  = "server-key.pem"
+	secretServerCert = "server-cert.pem"
+	secretCACert     = "ca-cert.pem"
+
+	// defaultingPath is where the MutatingWebhookConfiguration sends
+	// requests, and validationPath the ValidatingWebhookConfiguration.
+	defaultingPath = "/default"
+	validationPath = "/validate"
+)
+
+// ControllerOptions contains the configuration for the webhook.
+type ControllerOptions struct {
+	// WebhookName is the name of the webhook configurations we create to
+	// handle admissions. The same name is used for both the mutating and
+	// the validating configuration.
+	WebhookName string
+
+	// ServiceName is the service name of the webhook.
+	ServiceName string
+
+	// Namespace is the namespace of the webhook service and of the secret
+	// holding its certificates.
+	Namespace string
+
+	// SecretName is the name of the secret holding the webhook's
+	// certificates. It is created if it doesn't exist yet.
+	SecretName string
+
+	// Port where the webhook is served.
+	Port int
+
+	// RegistrationDelay controls how long the webhook waits after starting
+	// to serve before registering itself with the API server.
+	RegistrationDelay time.Duration
+}
+
+// GenericCRD is the interface of the resources the webhook can default and
+// validate. Resources that also implement apis.Immutable get their updates
+// checked with CheckImmutableFields.
+type GenericCRD interface {
+	apis.Defaultable
+	apis.Validatable
+	runtime.Object
+}
+
+// AdmissionController implements the defaulting and validating admission
+// webhooks. Defaults are applied before validation, as the API server calls
+// mutating webhooks first.
+type AdmissionController struct {
+	Client   kubernetes.Interface
+	Options  ControllerOptions
+	Handlers map[schema.GroupVersionKind]GenericCRD
+	Logger   *zap.SugaredLogger
+}
+
+// Run starts serving the webhook and registers it with the API server. It
+// blocks until stop is closed.
+func (ac *AdmissionController) Run(stop <-chan struct{}) error {
+	serverKey, serverCert, caCert, err := ac.getOrGenerateCerts()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:   ac,
+		Addr:      fmt.Sprintf(":%d", ac.Options.Port),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			errCh <- fmt.Errorf("webhook server failed: %v", err)
+		}
+	}()
+
+	select {
+	case <-time.After(ac.Options.RegistrationDelay):
+		if err := ac.register(caCert); err != nil {
+			server.Close()
+			return fmt.Errorf("failed to register the webhook: %v", err)
+		}
+		ac.Logger.Infof("Registered webhook %q", ac.Options.WebhookName)
+	case err := <-errCh:
+		return err
+	case <-stop:
+		return server.Close()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stop:
+		return server.Close()
+	}
+}
+
+// getOrGenerateCerts returns the certificates stored in the secret of the
+// webhook, creating them and the secret if needed.
+func (ac *AdmissionController) getOrGenerateCerts() (serverKey, serverCert, caCert []byte, err error) {
+	secrets := ac.Client.CoreV1().Secrets(ac.Options.Namespace)
+	secret, err := secrets.Get(ac.Options.SecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		serverKey, serverCert, caCert, err := CreateCerts(ac.Options.ServiceName, ac.Options.Namespace)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ac.Options.SecretName,
+				Namespace: ac.Options.Namespace,
+			},
+			Data: map[string][]byte{
+				secretServerKey:  serverKey,
+				secretServerCert: serverCert,
+				secretCACert:     caCert,
+			},
+		}
+		secret, err = secrets.Create(secret)
+		if apierrors.IsAlreadyExists(err) {
+			// Another replica got there first, use its certificates.
+			secret, err = secrets.Get(ac.Options.SecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var ok bool
+	if serverKey, ok = secret.Data[secretServerKey]; !ok {
+		return nil, nil, nil, fmt.Errorf("secret %q is missing %q", ac.Options.SecretName, secretServerKey)
+	}
+	if serverCert, ok = secret.Data[secretServerCert]; !ok {
+		return nil, nil, nil, fmt.Errorf("secret %q is missing %q", ac.Options.SecretName, secretServerCert)
+	}
+	if caCert, ok = secret.Data[secretCACert]; !ok {
+		return nil, nil, nil, fmt.Errorf("secret %q is missing %q", ac.Options.SecretName, secretCACert)
+	}
+	return serverKey, serverCert, caCert, nil
+}
+
+// register creates or updates the webhook configurations that send the
+// admission requests for the handled resources to the webhook.
+func (ac *AdmissionController) register(caCert []byte) error {
+	if err := ac.registerMutating(caCert); err != nil {
+		return err
+	}
+	return ac.registerValidating(caCert)
+}
+
+// webhook returns the webhook for the handled resources served at the given
+// path.
+func (ac *AdmissionController) webhook(path string, caCert []byte) admissionregistrationv1beta1.Webhook {
+	var rules []admissionregistrationv1beta1.RuleWithOperations
+	for gvk := range ac.Handlers {
+		rules = append(rules, admissionregistrationv1beta1.RuleWithOperations{
+			Operations: []admissionregistrationv1beta1.OperationType{
+				admissionregistrationv1beta1.Create,
+				admissionregistrationv1beta1.Update,
+			},
+			Rule: admissionregistrationv1beta1.Rule{
+				APIGroups:   []string{gvk.Group},
+				APIVersions: []string{gvk.Version},
+				Resources:   []string{apis.KindToResource(gvk).Resource},
+			},
+		})
+	}
+	// Sort the rules so that the configuration only changes when the
+	// handlers do.
+	sort.Slice(rules, func(i, j int) bool {
+		return fmt.Sprint(rules[i].Rule) < fmt.Sprint(rules[j].Rule)
+	})
+
+	failurePolicy := admissionregistrationv1beta1.Fail
+	return admissionregistrationv1beta1.Webhook{
+		Name:  ac.Options.WebhookName,
+		Rules: rules,
+		ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+			Service: &admissionregistrationv1beta1.ServiceReference{
+				Namespace: ac.Options.Namespace,
+				Name:      ac.Options.ServiceName,
+				Path:      &path,
+			},
+			CABundle: caCert,
+		},
+		FailurePolicy: &failurePolicy,
+	}
+}
+
+func (ac *AdmissionController) registerMutating(caCert []byte) error {
+	webhook := &admissionregistrationv1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ac.Options.WebhookName,
+		},
+		Webhooks: []admissionregistrationv1beta1.Webhook{ac.webhook(defaultingPath, caCert)},
+	}
+
+	client := ac.Client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+	existing, err := client.Get(ac.Options.WebhookName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(webhook)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(existing.Webhooks, webhook.Webhooks) {
+		return nil
+	}
+	existing.Webhooks = webhook.Webhooks
+	_, err = client.Update(existing)
+	return err
+}
+
+func (ac *AdmissionController) registerValidating(caCert []byte) error {
+	webhook := &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ac.Options.WebhookName,
+		},
+		Webhooks: []admissionregistrationv1beta1.Webhook{ac.webhook(validationPath, caCert)},
+	}
+
+	client := ac.Client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+	existing, err := client.Get(ac.Options.WebhookName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(webhook)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(existing.Webhooks, webhook.Webhooks) {
+		return nil
+	}
+	existing.Webhooks = webhook.Webhooks
+	_, err = client.Update(existing)
+	return err
+}
+
+// ServeHTTP implements http.Handler, answering AdmissionReviews.
+func (ac *AdmissionController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1beta1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+
+	var response *admissionv1beta1.AdmissionResponse
+	switch r.URL.Path {
+	case defaultingPath:
+		response = ac.admit(review.Request, ac.mutate)
+	case validationPath:
+		response = ac.admit(review.Request, ac.validate)
+	default:
+		http.Error(w, fmt.Sprintf("unknown path %q", r.URL.Path), http.StatusNotFound)
+		return
+	}
+	response.UID = review.Request.UID
+	ac.Logger.Infof("Admission of %s %s/%s: allowed=%v", review.Request.Kind.Kind,
+		review.Request.Namespace, review.Request.Name, response.Allowed)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(admissionv1beta1.AdmissionReview{Response: response}); err != nil {
+		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// admit answers the given request with the result of f, which returns the
+// JSON patch to apply to the object, if any.
+func (ac *AdmissionController) admit(request *admissionv1beta1.AdmissionRequest, f func(GenericCRD, *admissionv1beta1.AdmissionRequest) ([]byte, error)) *admissionv1beta1.AdmissionResponse {
+	switch request.Operation {
+	case admissionv1beta1.Create, admissionv1beta1.Update:
+	default:
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	gvk := schema.GroupVersionKind{
+		Group:   request.Kind.Group,
+		Version: request.Kind.Version,
+		Kind:    request.Kind.Kind,
+	}
+	handler, ok := ac.Handlers[gvk]
+	if !ok {
+		return errorResponse(fmt.Errorf("unhandled kind: %v", gvk))
+	}
+
+	patch, err := f(handler, request)
+	if err != nil {
+		return errorResponse(err)
+	}
+	response := &admissionv1beta1.AdmissionResponse{Allowed: true}
+	if patch != nil {
+		patchType := admissionv1beta1.PatchTypeJSONPatch
+		response.Patch = patch
+		response.PatchType = &patchType
+	}
+	return response
+}
+
+func errorResponse(err error) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		},
+	}
+}
+
+// mutate returns the JSON patch that sets the defaults of the object of the
+// given request.
+func (ac *AdmissionController) mutate(handler GenericCRD, request *admissionv1beta1.AdmissionRequest) ([]byte, error) {
+	obj, err := decode(handler, request.Object.Raw)
+	if err != nil {
+		return nil, err
+	}
+	before, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	obj.SetDefaults()
+	after, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both sides are diffed as re-encoded by the handler, so that the patch
+	// only contains the defaults rather than differences in encoding.
+	patch, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		return nil, err
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(patch)
+}
+
+// validate checks the object of the given request, and on updates that its
+// immutable fields haven't changed. It never patches the object.
+func (ac *AdmissionController) validate(handler GenericCRD, request *admissionv1beta1.AdmissionRequest) ([]byte, error) {
+	newObj, err := decode(handler, request.Object.Raw)
+	if err != nil {
+		return nil, err
+	}
+	// Let objects that are going away, for example when their finalizer is
+	// removed, through.
+	if accessor, err := meta.Accessor(newObj); err == nil && accessor.GetDeletionTimestamp() != nil {
+		return nil, nil
+	}
+	if err := newObj.Validate(); err != nil {
+		return nil, err
+	}
+
+	if request.Operation != admissionv1beta1.Update {
+		return nil, nil
+	}
+	immutable, ok := newObj.(apis.Immutable)
+	if !ok {
+		return nil, nil
+	}
+	oldObj, err := decode(handler, request.OldObject.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := immutable.CheckImmutableFields(oldObj.(apis.Immutable)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// decode decodes raw into a new object of the same type as handler.
+func decode(handler GenericCRD, raw []byte) (GenericCRD, error) {
+	obj := reflect.New(reflect.TypeOf(handler).Elem()).Interface().(GenericCRD)
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return nil, fmt.Errorf("could not decode object: %v", err)
+	}
+	return obj, nil
+}
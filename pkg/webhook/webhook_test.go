@@ -0,0 +1,248 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mattbaird/jsonpatch"
+	"go.uber.org/zap"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vaikas-google/csr/pkg/apis/cloudschedulersource/v1alpha1"
+)
+
+const testUID = types.UID("d2b1a5f0-0000-0000-0000-000000000000")
+
+var sourceKind = metav1.GroupVersionKind{
+	Group:   v1alpha1.SchemeGroupVersion.Group,
+	Version: v1alpha1.SchemeGroupVersion.Version,
+	Kind:    "CloudSchedulerSource",
+}
+
+func newAdmissionController() *AdmissionController {
+	return &AdmissionController{
+		Handlers: map[schema.GroupVersionKind]GenericCRD{
+			v1alpha1.SchemeGroupVersion.WithKind("CloudSchedulerSource"): &v1alpha1.CloudSchedulerSource{},
+		},
+		Logger: zap.NewNop().Sugar(),
+	}
+}
+
+// source returns a valid source, without defaults.
+func source(opts ...func(*v1alpha1.CloudSchedulerSource)) *v1alpha1.CloudSchedulerSource {
+	csr := &v1alpha1.CloudSchedulerSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "default"},
+		Spec: v1alpha1.CloudSchedulerSourceSpec{
+			GoogleCloudProject: "project",
+			Location:           "us-central1",
+			Schedule:           "*/5 * * * *",
+			Sink: &corev1.ObjectReference{
+				APIVersion: "eventing.knative.dev/v1alpha1",
+				Kind:       "Channel",
+				Name:       "channel",
+			},
+		},
+	}
+	for _, opt := range opts {
+		opt(csr)
+	}
+	return csr
+}
+
+func defaulted(csr *v1alpha1.CloudSchedulerSource) {
+	csr.SetDefaults()
+}
+
+func raw(t *testing.T, obj interface{}) runtime.RawExtension {
+	t.Helper()
+	if obj == nil {
+		return runtime.RawExtension{}
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Failed to marshal %v: %v", obj, err)
+	}
+	return runtime.RawExtension{Raw: b}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		operation admissionv1beta1.Operation
+		kind      metav1.GroupVersionKind
+		object    interface{}
+		oldObject interface{}
+
+		wantAllowed bool
+		wantErr     string
+		wantPatch   []jsonpatch.JsonPatchOperation
+	}{{
+		name:        "defaults",
+		path:        defaultingPath,
+		operation:   admissionv1beta1.Create,
+		object:      source(),
+		wantAllowed: true,
+		wantPatch: []jsonpatch.JsonPatchOperation{
+			{Operation: "add", Path: "/spec/timezone", Value: "UTC"},
+			{Operation: "add", Path: "/spec/httpMethod", Value: "POST"},
+			{Operation: "add", Path: "/spec/serviceAccountName", Value: "default"},
+		},
+	}, {
+		name:        "nothing to default",
+		path:        defaultingPath,
+		operation:   admissionv1beta1.Update,
+		object:      source(defaulted),
+		oldObject:   source(defaulted),
+		wantAllowed: true,
+	}, {
+		name:        "valid",
+		path:        validationPath,
+		operation:   admissionv1beta1.Create,
+		object:      source(defaulted),
+		wantAllowed: true,
+	}, {
+		name:      "invalid",
+		path:      validationPath,
+		operation: admissionv1beta1.Create,
+		object: source(defaulted, func(csr *v1alpha1.CloudSchedulerSource) {
+			csr.Spec.Schedule = ""
+		}),
+		wantErr: "missing field(s): spec.schedule",
+	}, {
+		name:      "immutable field changed",
+		path:      validationPath,
+		operation: admissionv1beta1.Update,
+		object: source(defaulted, func(csr *v1alpha1.CloudSchedulerSource) {
+			csr.Spec.GoogleCloudProject = "other"
+		}),
+		oldObject: source(defaulted),
+		wantErr:   "Immutable fields changed",
+	}, {
+		name:      "invalid but deleted",
+		path:      validationPath,
+		operation: admissionv1beta1.Update,
+		object: source(defaulted, func(csr *v1alpha1.CloudSchedulerSource) {
+			now := metav1.Now()
+			csr.DeletionTimestamp = &now
+			csr.Spec.Schedule = ""
+		}),
+		oldObject:   source(defaulted),
+		wantAllowed: true,
+	}, {
+		name:        "delete",
+		path:        validationPath,
+		operation:   admissionv1beta1.Delete,
+		wantAllowed: true,
+	}, {
+		name:      "unhandled kind",
+		path:      validationPath,
+		operation: admissionv1beta1.Create,
+		kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		object:    source(defaulted),
+		wantErr:   "unhandled kind",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			kind := tc.kind
+			if kind.Kind == "" {
+				kind = sourceKind
+			}
+			review := admissionv1beta1.AdmissionReview{
+				Request: &admissionv1beta1.AdmissionRequest{
+					UID:       testUID,
+					Kind:      kind,
+					Operation: tc.operation,
+					Object:    raw(t, tc.object),
+					OldObject: raw(t, tc.oldObject),
+				},
+			}
+			body, err := json.Marshal(review)
+			if err != nil {
+				t.Fatalf("Failed to marshal the review: %v", err)
+			}
+			w := httptest.NewRecorder()
+			newAdmissionController().ServeHTTP(w, httptest.NewRequest("POST", tc.path, bytes.NewReader(body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+
+			var got admissionv1beta1.AdmissionReview
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode the response: %v", err)
+			}
+			response := got.Response
+			if response == nil {
+				t.Fatal("Response is missing")
+			}
+			if response.UID != testUID {
+				t.Errorf("UID = %q, want %q", response.UID, testUID)
+			}
+			if tc.wantErr != "" {
+				if response.Allowed || response.Result == nil || !strings.Contains(response.Result.Message, tc.wantErr) {
+					t.Errorf("Response = %+v, want denied with %q", response, tc.wantErr)
+				}
+				return
+			}
+			if response.Allowed != tc.wantAllowed {
+				t.Errorf("Allowed = %v, want %v: %+v", response.Allowed, tc.wantAllowed, response.Result)
+			}
+
+			var patch []jsonpatch.JsonPatchOperation
+			if response.Patch != nil {
+				if response.PatchType == nil || *response.PatchType != admissionv1beta1.PatchTypeJSONPatch {
+					t.Errorf("PatchType = %v, want %s", response.PatchType, admissionv1beta1.PatchTypeJSONPatch)
+				}
+				if err := json.Unmarshal(response.Patch, &patch); err != nil {
+					t.Fatalf("Failed to decode the patch %s: %v", response.Patch, err)
+				}
+			}
+			sortOps := cmpopts.SortSlices(func(a, b jsonpatch.JsonPatchOperation) bool { return a.Path < b.Path })
+			if diff := cmp.Diff(tc.wantPatch, patch, sortOps, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Patch (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestServeHTTPUnknownPath(t *testing.T) {
+	body, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		Request: &admissionv1beta1.AdmissionRequest{Kind: sourceKind, Operation: admissionv1beta1.Create},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal the review: %v", err)
+	}
+	w := httptest.NewRecorder()
+	newAdmissionController().ServeHTTP(w, httptest.NewRequest("POST", "/other", bytes.NewReader(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}